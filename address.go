@@ -0,0 +1,59 @@
+package txeh
+
+import (
+	"net/netip"
+	"strings"
+)
+
+// parseAddr parses an IP literal into the canonical form addresses are
+// matched on: IPv4-mapped IPv6 addresses become IPv4, and zones are kept.
+func parseAddr(s string) (netip.Addr, bool) {
+	addr, err := netip.ParseAddr(strings.TrimSpace(s))
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap(), true
+}
+
+// addressMatcher matches lines by address. Valid addresses match on their
+// canonical form, so "::1" and "0:0:0:0:0:0:0:1" are the same address.
+// Anything else matches the line's text, ignoring case.
+type addressMatcher struct {
+	addr netip.Addr
+	text string
+}
+
+// newAddressMatcher returns a matcher for address.
+func newAddressMatcher(address string) addressMatcher {
+	address = strings.TrimSpace(address)
+	addr, _ := parseAddr(address)
+	return addressMatcher{addr: addr, text: address}
+}
+
+// matches reports whether hfl is a line for the address.
+func (m addressMatcher) matches(hfl *HostFileLine) bool {
+	if m.addr.IsValid() {
+		return hfl.addr == m.addr
+	}
+	return hfl.LineType == ADDRESS && !hfl.addr.IsValid() && strings.EqualFold(hfl.Address, m.text)
+}
+
+// addressKey returns the canonical address of hfl as a string, or its
+// lowercased text when the address does not parse.
+func addressKey(hfl *HostFileLine) string {
+	if hfl.addr.IsValid() {
+		return hfl.addr.String()
+	}
+	return strings.ToLower(hfl.Address)
+}
+
+// isLocalhost reports whether address is a loopback address.
+func isLocalhost(address string) bool {
+	addr, ok := parseAddr(address)
+	return ok && addr.IsLoopback()
+}
+
+// prefixContains reports whether prefix contains addr, ignoring its zone.
+func prefixContains(prefix netip.Prefix, addr netip.Addr) bool {
+	return addr.IsValid() && prefix.Contains(addr.WithZone(""))
+}
@@ -0,0 +1,86 @@
+package txeh
+
+import (
+	"slices"
+	"testing"
+)
+
+// Given addresses written in different but equivalent forms
+// When hosts are listed, added and removed by address
+// Then the forms match each other while each line keeps its original text.
+func TestAddress_CanonicalMatching(t *testing.T) {
+	hosts := newRawHosts(t, "0:0:0:0:0:0:0:1 localhost6\n::FFFF:10.0.0.1 mapped\nfe80::1%eth0 link\n")
+
+	if got := hosts.ListHostsByIP("::1"); !slices.Equal(got, []string{"localhost6"}) {
+		t.Errorf("ListHostsByIP(::1) = %v", got)
+	}
+	if got := hosts.ListHostsByIP("10.0.0.1"); !slices.Equal(got, []string{"mapped"}) {
+		t.Errorf("ListHostsByIP(10.0.0.1) = %v", got)
+	}
+	if got := hosts.ListHostsByIP("fe80::1%eth0"); !slices.Equal(got, []string{"link"}) {
+		t.Errorf("ListHostsByIP(fe80::1%%eth0) = %v", got)
+	}
+	if got := hosts.ListHostsByIP("fe80::1"); len(got) != 0 {
+		t.Errorf("ListHostsByIP(fe80::1) without zone = %v, want none", got)
+	}
+
+	hosts.AddHost("::1", "ip6-localhost")
+	hosts.AddHost("fe80::1%eth0", "link2")
+
+	want := "0:0:0:0:0:0:0:1  localhost6 ip6-localhost\n::FFFF:10.0.0.1  mapped\nfe80::1%eth0     link link2\n"
+	if got := hosts.RenderHostsFile(); got != want {
+		t.Errorf("RenderHostsFile() =\n%s\nwant:\n%s", got, want)
+	}
+
+	if !hosts.RemoveFirstAddress("10.0.0.1") {
+		t.Error("RemoveFirstAddress(10.0.0.1) did not match ::ffff:10.0.0.1")
+	}
+}
+
+// Given an IPv4-mapped IPv6 line
+// When the host is looked up and moved by IP family
+// Then it is treated as IPv4.
+func TestAddress_IPv4MappedIsIPv4(t *testing.T) {
+	hosts := newRawHosts(t, "::ffff:10.0.0.1 app\n")
+
+	found, address, _ := hosts.HostAddressLookup("app", IPFamilyV4)
+	if !found || address != "::ffff:10.0.0.1" {
+		t.Errorf("HostAddressLookup(app, v4) = %v, %q", found, address)
+	}
+
+	hosts.AddHost("10.0.0.2", "app")
+	if got := hosts.ListHostsByIP("10.0.0.2"); !slices.Equal(got, []string{"app"}) {
+		t.Errorf("app not moved to 10.0.0.2: %v", got)
+	}
+	if got := hosts.ListHostsByCIDR("10.0.0.0/24"); len(got) != 1 {
+		t.Errorf("ListHostsByCIDR() = %v", got)
+	}
+}
+
+// Given zoned and mapped addresses
+// When CIDRs are removed
+// Then addresses are matched on their canonical form without the zone.
+func TestAddress_RemoveCIDRs(t *testing.T) {
+	hosts := newRawHosts(t, "::ffff:10.0.0.1 a\nfe80::1%eth0 b\n192.168.0.1 c\n")
+
+	if err := hosts.RemoveCIDRs([]string{"10.0.0.0/8", "fe80::/10"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := hosts.RenderHostsFile(); got != "192.168.0.1      c\n" {
+		t.Errorf("RenderHostsFile() = %q", got)
+	}
+}
+
+func TestIsLocalhost_CanonicalForms(t *testing.T) {
+	for _, address := range []string{"0:0:0:0:0:0:0:1", "::ffff:127.0.0.1", " 127.0.0.1 "} {
+		if !isLocalhost(address) {
+			t.Errorf("isLocalhost(%q) = false", address)
+		}
+	}
+	for _, address := range []string{"localhost", "fe80::1%lo0", ""} {
+		if isLocalhost(address) {
+			t.Errorf("isLocalhost(%q) = true", address)
+		}
+	}
+}
@@ -0,0 +1,254 @@
+package txeh
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultBackupRetention is the number of backups kept when
+// HostsConfig.BackupRetention is zero.
+const DefaultBackupRetention = 10
+
+// backupInfix separates the hosts file name from the backup timestamp,
+// e.g. hosts.txeh-20261016120000.
+const backupInfix = ".txeh-"
+
+// backupTimeLayout is the timestamp format used in backup IDs (UTC).
+const backupTimeLayout = "20060102150405"
+
+// ErrBackupNotFound is returned by RestoreBackup when no backup has the given ID.
+var ErrBackupNotFound = errors.New("backup not found")
+
+// backupNow returns the current time for backup IDs. Tests replace it.
+var backupNow = time.Now
+
+// Backup describes a hosts file backup created before a write.
+type Backup struct {
+	// ID identifies the backup for RestoreBackup. It is the UTC timestamp of
+	// the backup (YYYYMMDDHHMMSS), with a "-N" suffix when several backups were
+	// taken within the same second.
+	ID string
+	// Path is the location of the backup file.
+	Path string
+	// Time is when the backup was taken.
+	Time time.Time
+	// Size is the size of the backup in bytes.
+	Size int64
+}
+
+// ListBackups returns the backups of the configured write path, newest first.
+func (h *Hosts) ListBackups() ([]Backup, error) {
+	if src := h.nonFileSource(); src != "" {
+		return nil, fmt.Errorf("cannot list backups with %s", src)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.listBackupsLocked(h.WriteFilePath)
+}
+
+// RestoreBackup replaces the hosts file at the configured write path with the
+// backup identified by id and loads it into memory. The backup is written back
+// byte for byte. When backups are enabled, the file being replaced is itself
+// backed up first, so a restore can be undone. A restore is a deliberate
+// overwrite and is not subject to the concurrent modification check.
+func (h *Hosts) RestoreBackup(id string) error {
+	if src := h.nonFileSource(); src != "" {
+		return fmt.Errorf("cannot restore a backup with %s", src)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	backups, err := h.listBackupsLocked(h.WriteFilePath)
+	if err != nil {
+		return err
+	}
+	idx := slices.IndexFunc(backups, func(b Backup) bool { return b.ID == id })
+	if idx < 0 {
+		return fmt.Errorf("%s: %w", id, ErrBackupNotFound)
+	}
+
+	data, err := os.ReadFile(filepath.Clean(backups[idx].Path))
+	if err != nil {
+		return fmt.Errorf("read backup %s: %w", backups[idx].Path, err)
+	}
+	hfl, format, err := readHosts(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+
+	release, err := h.lockForWriteLocked(h.WriteFilePath)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = release() }()
+
+	if err := h.writeDataLocked(h.WriteFilePath, data); err != nil {
+		return err
+	}
+	h.hostFileLines = hfl
+	h.invalidateIndexLocked()
+	h.format = format
+	h.pending = nil
+
+	return nil
+}
+
+// backupLocked copies the current content of fileName to a new timestamped
+// backup and prunes old backups beyond the retention count. A missing file has
+// nothing to back up. Must be called with the lock held.
+func (h *Hosts) backupLocked(fileName string) error {
+	target, err := resolveWriteTarget(filepath.Clean(fileName))
+	if err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(target) // #nosec G304 -- the hosts file about to be replaced
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read %s for backup: %w", target, err)
+	}
+
+	dir := h.backupDir(target)
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return fmt.Errorf("create backup directory %s: %w", dir, err)
+	}
+
+	prefix := filepath.Join(dir, filepath.Base(target)+backupInfix+backupNow().UTC().Format(backupTimeLayout))
+	path := prefix
+	for n := 1; ; n++ {
+		if _, err := os.Lstat(path); errors.Is(err, fs.ErrNotExist) {
+			break
+		}
+		path = prefix + "-" + strconv.Itoa(n)
+	}
+
+	if err := writeFileAtomic(path, data); err != nil {
+		return fmt.Errorf("write backup %s: %w", path, err)
+	}
+
+	return h.pruneBackupsLocked(fileName)
+}
+
+// pruneBackupsLocked removes the oldest backups of fileName beyond the
+// effective retention count. Must be called with the lock held.
+func (h *Hosts) pruneBackupsLocked(fileName string) error {
+	keep := h.getEffectiveBackupRetention()
+	if keep <= 0 {
+		return nil
+	}
+
+	backups, err := h.listBackupsLocked(fileName)
+	if err != nil {
+		return err
+	}
+
+	for _, b := range backups[min(keep, len(backups)):] {
+		if err := os.Remove(b.Path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("remove old backup %s: %w", b.Path, err)
+		}
+	}
+
+	return nil
+}
+
+// listBackupsLocked returns the backups of fileName, newest first.
+// Must be called with the lock held.
+func (h *Hosts) listBackupsLocked(fileName string) ([]Backup, error) {
+	target, err := resolveWriteTarget(filepath.Clean(fileName))
+	if err != nil {
+		return nil, err
+	}
+
+	dir := h.backupDir(target)
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read backup directory %s: %w", dir, err)
+	}
+
+	prefix := filepath.Base(target) + backupInfix
+	backups := make([]Backup, 0)
+	for _, e := range entries {
+		id, ok := strings.CutPrefix(e.Name(), prefix)
+		if !ok || !e.Type().IsRegular() {
+			continue
+		}
+		ts, _, ok := parseBackupID(id)
+		if !ok {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		backups = append(backups, Backup{
+			ID:   id,
+			Path: filepath.Join(dir, e.Name()),
+			Time: ts,
+			Size: info.Size(),
+		})
+	}
+
+	slices.SortFunc(backups, func(a, b Backup) int {
+		if c := b.Time.Compare(a.Time); c != 0 {
+			return c
+		}
+		_, sa, _ := parseBackupID(a.ID)
+		_, sb, _ := parseBackupID(b.ID)
+		return sb - sa
+	})
+
+	return backups, nil
+}
+
+// backupDir returns the directory backups of target are stored in.
+func (h *Hosts) backupDir(target string) string {
+	if h.BackupDir != "" {
+		return filepath.Clean(h.BackupDir)
+	}
+	return filepath.Dir(target)
+}
+
+// getEffectiveBackupRetention returns the number of backups to keep.
+// Returns 0 for unlimited.
+func (h *Hosts) getEffectiveBackupRetention() int {
+	switch {
+	case h.BackupRetention > 0:
+		return h.BackupRetention
+	case h.BackupRetention < 0:
+		return 0
+	default:
+		return DefaultBackupRetention
+	}
+}
+
+// parseBackupID splits a backup ID into its timestamp and same-second sequence number.
+func parseBackupID(id string) (ts time.Time, seq int, ok bool) {
+	stamp, suffix, hasSuffix := strings.Cut(id, "-")
+	ts, err := time.Parse(backupTimeLayout, stamp)
+	if err != nil {
+		return time.Time{}, 0, false
+	}
+	if hasSuffix {
+		seq, err = strconv.Atoi(suffix)
+		if err != nil || seq < 1 {
+			return time.Time{}, 0, false
+		}
+	}
+	return ts, seq, true
+}
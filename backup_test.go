@@ -0,0 +1,253 @@
+package txeh
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// stubBackupClock makes backupNow return successive seconds starting at start.
+func stubBackupClock(t *testing.T, start time.Time) {
+	t.Helper()
+	orig := backupNow
+	t.Cleanup(func() { backupNow = orig })
+	next := start
+	backupNow = func() time.Time {
+		now := next
+		next = next.Add(time.Second)
+		return now
+	}
+}
+
+// Given Backup is enabled
+// When the hosts file is saved
+// Then the previous content is kept in hosts.txeh-YYYYMMDDHHMMSS next to it.
+func TestBackup_CreatedBeforeWrite(t *testing.T) {
+	stubBackupClock(t, time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC))
+	path := writeHostsFile(t, testHostsLocalhost)
+
+	hosts, err := NewHosts(&HostsConfig{ReadFilePath: path, Backup: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hosts.AddHost(testIPv4Alt, "newhost")
+	if err := hosts.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	backupPath := path + ".txeh-20261016120000"
+	if got := readHostsFile(t, backupPath); got != testHostsLocalhost {
+		t.Errorf("backup content = %q, want original %q", got, testHostsLocalhost)
+	}
+
+	backups, err := hosts.ListBackups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 1 || backups[0].ID != "20261016120000" || backups[0].Path != backupPath {
+		t.Errorf("ListBackups() = %+v", backups)
+	}
+	if backups[0].Size != int64(len(testHostsLocalhost)) {
+		t.Errorf("Size = %d, want %d", backups[0].Size, len(testHostsLocalhost))
+	}
+}
+
+// Given Backup is disabled (the default)
+// When the hosts file is saved
+// Then no backup is created.
+func TestBackup_DisabledByDefault(t *testing.T) {
+	path := writeHostsFile(t, testHostsLocalhost)
+
+	hosts, err := NewHosts(&HostsConfig{ReadFilePath: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := hosts.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	backups, err := hosts.ListBackups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 0 {
+		t.Errorf("expected no backups, got %+v", backups)
+	}
+}
+
+// Given a BackupDir and a retention of 2
+// When the file is saved four times
+// Then only the two newest backups remain, in BackupDir, newest first.
+func TestBackup_RetentionAndDir(t *testing.T) {
+	stubBackupClock(t, time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC))
+	path := writeHostsFile(t, testHostsLocalhost)
+	dir := filepath.Join(t.TempDir(), "backups")
+
+	hosts, err := NewHosts(&HostsConfig{
+		ReadFilePath:    path,
+		Backup:          true,
+		BackupDir:       dir,
+		BackupRetention: 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range 4 {
+		hosts.AddHost(testIPv4Alt, "host"+string(rune('a'+i)))
+		if err := hosts.Save(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	backups, err := hosts.ListBackups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 2 {
+		t.Fatalf("expected 2 backups, got %d: %+v", len(backups), backups)
+	}
+	if backups[0].ID != "20261016120003" || backups[1].ID != "20261016120002" {
+		t.Errorf("unexpected backup order: %s, %s", backups[0].ID, backups[1].ID)
+	}
+	if filepath.Dir(backups[0].Path) != dir {
+		t.Errorf("backup stored in %s, want %s", filepath.Dir(backups[0].Path), dir)
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(path)); len(entries) != 1 {
+		t.Errorf("backups should not be written next to the hosts file when BackupDir is set")
+	}
+}
+
+// Given two backups taken within the same second
+// When they are listed
+// Then the second one gets a "-1" suffix and sorts first.
+func TestBackup_SameSecondGetsSuffix(t *testing.T) {
+	orig := backupNow
+	t.Cleanup(func() { backupNow = orig })
+	fixed := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	backupNow = func() time.Time { return fixed }
+
+	path := writeHostsFile(t, testHostsLocalhost)
+	hosts, err := NewHosts(&HostsConfig{ReadFilePath: path, Backup: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range 2 {
+		if err := hosts.Save(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	backups, err := hosts.ListBackups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 2 || backups[0].ID != "20261016120000-1" || backups[1].ID != "20261016120000" {
+		t.Errorf("ListBackups() = %+v", backups)
+	}
+}
+
+// Given a backup of the original file
+// When RestoreBackup is called after a destructive change
+// Then the file and in-memory state match the backup byte for byte.
+func TestRestoreBackup_RestoresContent(t *testing.T) {
+	stubBackupClock(t, time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC))
+	original := "127.0.0.1   localhost\n10.0.0.1    keepme   # hand aligned\n"
+	path := writeHostsFile(t, original)
+
+	hosts, err := NewHosts(&HostsConfig{ReadFilePath: path, Backup: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := hosts.RemoveCIDRs([]string{"10.0.0.0/8"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := hosts.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(readHostsFile(t, path), "keepme") {
+		t.Fatal("setup: host should have been removed")
+	}
+
+	if err := hosts.RestoreBackup("20261016120000"); err != nil {
+		t.Fatalf("RestoreBackup() error: %v", err)
+	}
+	if got := readHostsFile(t, path); got != original {
+		t.Errorf("restored content = %q, want %q", got, original)
+	}
+	if got := hosts.ListHostsByIP("10.0.0.1"); len(got) != 1 {
+		t.Errorf("in-memory state not restored, got %v", got)
+	}
+
+	// The restore itself was backed up, so it can be undone.
+	backups, _ := hosts.ListBackups()
+	if len(backups) != 2 {
+		t.Errorf("expected the replaced file to be backed up, got %+v", backups)
+	}
+
+	// The restored content is the new baseline for Save.
+	if err := hosts.Save(); err != nil {
+		t.Errorf("Save() after restore error: %v", err)
+	}
+}
+
+// Given no backup with the requested ID
+// When RestoreBackup is called
+// Then it returns ErrBackupNotFound.
+func TestRestoreBackup_NotFound(t *testing.T) {
+	path := writeHostsFile(t, testHostsLocalhost)
+	hosts, err := NewHosts(&HostsConfig{ReadFilePath: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := hosts.RestoreBackup("20000101000000"); !errors.Is(err, ErrBackupNotFound) {
+		t.Errorf("expected ErrBackupNotFound, got %v", err)
+	}
+}
+
+// Given a RawText instance
+// When backups are listed or restored
+// Then an error is returned.
+func TestBackup_RawText_ReturnsError(t *testing.T) {
+	raw := testHostsLocalhost
+	hosts, err := NewHosts(&HostsConfig{RawText: &raw})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := hosts.ListBackups(); err == nil {
+		t.Error("ListBackups with RawText should return error")
+	}
+	if err := hosts.RestoreBackup("x"); err == nil {
+		t.Error("RestoreBackup with RawText should return error")
+	}
+}
+
+// Given assorted backup ID strings
+// When parseBackupID is called
+// Then only well-formed IDs are accepted.
+func TestParseBackupID(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		id      string
+		wantOK  bool
+		wantSeq int
+	}{
+		{"20261016120000", true, 0},
+		{"20261016120000-3", true, 3},
+		{"20261016120000-0", false, 0},
+		{"20261016120000-x", false, 0},
+		{"not-a-backup", false, 0},
+		{"", false, 0},
+	}
+
+	for _, tt := range tests {
+		_, seq, ok := parseBackupID(tt.id)
+		if ok != tt.wantOK || seq != tt.wantSeq {
+			t.Errorf("parseBackupID(%q) = (%d, %v), want (%d, %v)", tt.id, seq, ok, tt.wantSeq, tt.wantOK)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package txeh
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// benchSizes are the hosts file sizes, in lines, the benchmarks run against.
+var benchSizes = []int{10_000, 100_000, 1_000_000}
+
+// benchLines returns a parsed hosts file of n address lines, one hostname
+// each, spread over 10.0.0.0/8 like a large ad-blocking or generated file.
+func benchLines(b *testing.B, n int) HostFileLines {
+	b.Helper()
+
+	var sb strings.Builder
+	sb.WriteString("127.0.0.1 localhost\n")
+	for i := range n - 1 {
+		fmt.Fprintf(&sb, "10.%d.%d.%d host-%d.example\n", (i>>16)&255, (i>>8)&255, i&255, i)
+	}
+
+	lines, err := ParseHostsFromString(sb.String())
+	if err != nil {
+		b.Fatal(err)
+	}
+	return lines
+}
+
+// benchColdHosts returns a Hosts over a copy of lines whose lookup index is
+// not built yet, as right after the file is read.
+func benchColdHosts(lines HostFileLines) *Hosts {
+	return &Hosts{HostsConfig: &HostsConfig{MaxHostsPerLine: -1}, hostFileLines: cloneHostFileLines(lines)}
+}
+
+// benchHosts returns a Hosts over a copy of lines that has answered one
+// lookup, as a long-running user such as kubefwd would have. The index is
+// built outside the timer, so the benchmarks using it leave that one-time
+// cost out; BenchmarkFirstLookup measures it.
+func benchHosts(lines HostFileLines) *Hosts {
+	h := benchColdHosts(lines)
+	h.HostAddressLookup("localhost", IPFamilyV4)
+	return h
+}
+
+// runBench runs op against a fresh Hosts of every benchmark size.
+func runBench(b *testing.B, op func(h *Hosts, n int)) {
+	runBenchWith(b, benchHosts, op)
+}
+
+// runBenchWith is runBench with the Hosts built by newHosts.
+func runBenchWith(b *testing.B, newHosts func(HostFileLines) *Hosts, op func(h *Hosts, n int)) {
+	for _, n := range benchSizes {
+		b.Run(fmt.Sprintf("lines=%d", n), func(b *testing.B) {
+			lines := benchLines(b, n)
+			b.ResetTimer()
+			for range b.N {
+				b.StopTimer()
+				h := newHosts(lines)
+				b.StartTimer()
+				op(h, n)
+			}
+		})
+	}
+}
+
+// BenchmarkAddHosts adds 200 new services to their own loopback addresses,
+// like kubefwd at startup.
+func BenchmarkAddHosts(b *testing.B) {
+	runBench(b, func(h *Hosts, _ int) {
+		for i := range 200 {
+			h.AddHostsWithComment(fmt.Sprintf("127.1.%d.%d", i/250, i%250+1), []string{fmt.Sprintf("svc-%d", i), fmt.Sprintf("svc-%d.default", i)}, "kubefwd")
+		}
+	})
+}
+
+// BenchmarkRemoveHosts removes 200 hostnames spread over the file.
+func BenchmarkRemoveHosts(b *testing.B) {
+	runBench(b, func(h *Hosts, n int) {
+		hosts := make([]string, 200)
+		for i := range hosts {
+			hosts[i] = fmt.Sprintf("host-%d.example", i*(n/200))
+		}
+		h.RemoveHosts(hosts)
+	})
+}
+
+// BenchmarkRemoveAddresses removes 200 addresses spread over the file.
+func BenchmarkRemoveAddresses(b *testing.B) {
+	runBench(b, func(h *Hosts, n int) {
+		addresses := make([]string, 200)
+		for i := range addresses {
+			j := i * (n / 200)
+			addresses[i] = fmt.Sprintf("10.%d.%d.%d", (j>>16)&255, (j>>8)&255, j&255)
+		}
+		h.RemoveAddresses(addresses)
+	})
+}
+
+// BenchmarkRemoveCIDRs removes 20 /24 ranges.
+func BenchmarkRemoveCIDRs(b *testing.B) {
+	runBench(b, func(h *Hosts, _ int) {
+		cidrs := make([]string, 20)
+		for i := range cidrs {
+			cidrs[i] = fmt.Sprintf("10.0.%d.0/24", i)
+		}
+		if err := h.RemoveCIDRs(cidrs); err != nil {
+			b.Fatal(err)
+		}
+	})
+}
+
+// BenchmarkHostAddressLookup looks up 200 hostnames.
+func BenchmarkHostAddressLookup(b *testing.B) {
+	runBench(b, func(h *Hosts, n int) {
+		for i := range 200 {
+			h.HostAddressLookup(fmt.Sprintf("host-%d.example", i*(n/200)), IPFamilyV4)
+		}
+	})
+}
+
+// BenchmarkFirstLookup looks up one hostname on a freshly parsed file, which
+// includes building the lookup index, as a short-lived CLI call does.
+func BenchmarkFirstLookup(b *testing.B) {
+	runBenchWith(b, benchColdHosts, func(h *Hosts, n int) {
+		h.HostAddressLookup(fmt.Sprintf("host-%d.example", n/2), IPFamilyV4)
+	})
+}
@@ -0,0 +1,223 @@
+package txeh
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Block markers delimit an area of the hosts file owned by one tool:
+//
+//	# BEGIN txeh:kubefwd
+//	127.1.27.1       svc.default
+//	# END txeh:kubefwd
+const (
+	blockBeginKeyword = "BEGIN txeh:"
+	blockEndKeyword   = "END txeh:"
+)
+
+// ErrInvalidBlockName is returned when a block name is empty or contains
+// characters other than letters, digits, '.', '_' and '-'.
+var ErrInvalidBlockName = errors.New("invalid block name")
+
+// ErrMalformedBlock is returned by SetBlock when the file has a BEGIN marker
+// for the block without a matching END marker. The extent of such a block is
+// unknown, so it has to be fixed by hand.
+var ErrMalformedBlock = errors.New("block has a BEGIN marker without a matching END marker")
+
+// blockNameRegex matches valid block names.
+var blockNameRegex = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// BlockEntry is an address line inside a managed block.
+type BlockEntry struct {
+	Address   string
+	Hostnames []string
+	Comment   string
+}
+
+// SetBlock replaces the content of the block called name with entries,
+// creating the block at the end of the file if it does not exist yet. The
+// replacement is a single mutation: other goroutines see either the old or
+// the new block. Lines outside the block are never touched, so a hostname set
+// here may also appear in hand-written entries elsewhere.
+//
+// Entries with more hostnames than the effective MaxHostsPerLine are split
+// across several lines. An empty entries slice leaves an empty block in place;
+// use RemoveBlock to delete the markers too.
+func (h *Hosts) SetBlock(name string, entries []BlockEntry) error {
+	if !blockNameRegex.MatchString(name) {
+		return fmt.Errorf("%q: %w", name, ErrInvalidBlockName)
+	}
+
+	entries = slices.Clone(entries)
+	for i, e := range entries {
+		address := strings.TrimSpace(e.Address)
+		if err := ValidateAddress(address); err != nil {
+			return fmt.Errorf("block %s: %w", name, err)
+		}
+		if len(e.Hostnames) == 0 {
+			return fmt.Errorf("block %s: entry for %s has no hostnames", name, address)
+		}
+		hostnames := make([]string, len(e.Hostnames))
+		for j, hn := range e.Hostnames {
+			hn, err := NormalizeHostname(hn)
+			if err != nil {
+				return fmt.Errorf("block %s: %w", name, err)
+			}
+			hostnames[j] = hn
+		}
+		entries[i] = BlockEntry{
+			Address:   address,
+			Hostnames: hostnames,
+			Comment:   stripLineBreaks(strings.TrimSpace(e.Comment)),
+		}
+	}
+
+	return h.mutateErr(func(x *Hosts) error { return x.setBlockLocked(name, entries) })
+}
+
+// RemoveBlock deletes the block called name, markers included, and reports
+// whether it was found.
+func (h *Hosts) RemoveBlock(name string) bool {
+	removed := false
+	h.mutate(func(x *Hosts) { removed = x.removeBlockLocked(name) })
+	return removed
+}
+
+// Block returns the address entries of the block called name. Comment lines
+// inside the block are not returned. The second result is false when the
+// block does not exist or has no END marker.
+func (h *Hosts) Block(name string) ([]BlockEntry, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	begin, end, ok := h.findBlockLocked(name)
+	if !ok || end < 0 {
+		return nil, false
+	}
+
+	entries := make([]BlockEntry, 0, end-begin-1)
+	for _, hfl := range h.hostFileLines[begin+1 : end] {
+		if hfl.LineType != ADDRESS {
+			continue
+		}
+		entries = append(entries, BlockEntry{
+			Address:   hfl.Address,
+			Hostnames: slices.Clone(hfl.Hostnames),
+			Comment:   hfl.Comment,
+		})
+	}
+
+	return entries, true
+}
+
+// setBlockLocked replaces or appends the block. Must be called with the lock held.
+func (h *Hosts) setBlockLocked(name string, entries []BlockEntry) error {
+	begin, end, ok := h.findBlockLocked(name)
+	if ok && end < 0 {
+		return fmt.Errorf("block %s: %w", name, ErrMalformedBlock)
+	}
+
+	lines := []HostFileLine{blockMarkerLine(blockBeginKeyword + name)}
+	maxPerLine := h.getEffectiveMaxHostsPerLine()
+	for _, e := range entries {
+		addr, _ := parseAddr(e.Address)
+		size := maxPerLine
+		if size <= 0 {
+			size = len(e.Hostnames)
+		}
+		for chunk := range slices.Chunk(e.Hostnames, size) {
+			lines = append(lines, HostFileLine{
+				LineType:  ADDRESS,
+				Address:   e.Address,
+				Hostnames: slices.Clone(chunk),
+				Comment:   e.Comment,
+				Tags:      ParseTags(e.Comment),
+				addr:      addr,
+			})
+		}
+	}
+	lines = append(lines, blockMarkerLine(blockEndKeyword+name))
+
+	if !ok {
+		h.hostFileLines = append(h.hostFileLines, lines...)
+		h.invalidateIndexLocked()
+		return nil
+	}
+
+	h.hostFileLines = slices.Replace(h.hostFileLines, begin, end+1, lines...)
+	// A name should appear once; drop any later duplicates.
+	after := begin + len(lines)
+	rest := &Hosts{hostFileLines: h.hostFileLines[after:]}
+	rest.removeBlockLocked(name)
+	h.hostFileLines = append(h.hostFileLines[:after], rest.hostFileLines...)
+	h.invalidateIndexLocked()
+
+	return nil
+}
+
+// removeBlockLocked removes every complete block called name and reports
+// whether any was found. Must be called with the lock held.
+func (h *Hosts) removeBlockLocked(name string) bool {
+	begin, end, ok := h.findBlockLocked(name)
+	if !ok || end < 0 {
+		return false
+	}
+
+	h.hostFileLines = slices.Delete(h.hostFileLines, begin, end+1)
+	h.invalidateIndexLocked()
+	h.removeBlockLocked(name)
+
+	return true
+}
+
+// findBlockLocked returns the line indexes of the BEGIN and END markers of the
+// first block called name. end is -1 when the BEGIN marker is not closed.
+// Must be called with the lock held.
+func (h *Hosts) findBlockLocked(name string) (begin, end int, ok bool) {
+	begin = -1
+	for i, hfl := range h.hostFileLines {
+		marker, isBegin, isMarker := parseBlockMarker(hfl)
+		if !isMarker || marker != name {
+			continue
+		}
+		if begin < 0 && isBegin {
+			begin = i
+			continue
+		}
+		if begin >= 0 && !isBegin {
+			return begin, i, true
+		}
+	}
+
+	if begin < 0 {
+		return -1, -1, false
+	}
+	return begin, -1, true
+}
+
+// parseBlockMarker reports whether hfl is a block marker, returning the block
+// name and whether it is a BEGIN (rather than END) marker.
+func parseBlockMarker(hfl HostFileLine) (name string, begin, ok bool) {
+	if hfl.LineType != COMMENT {
+		return "", false, false
+	}
+
+	text := strings.TrimSpace(strings.TrimPrefix(hfl.Trimmed, "#"))
+	if name, ok := strings.CutPrefix(text, blockBeginKeyword); ok {
+		return strings.TrimSpace(name), true, true
+	}
+	if name, ok := strings.CutPrefix(text, blockEndKeyword); ok {
+		return strings.TrimSpace(name), false, true
+	}
+
+	return "", false, false
+}
+
+// blockMarkerLine returns a comment line holding a block marker.
+func blockMarkerLine(marker string) HostFileLine {
+	raw := "# " + marker
+	return HostFileLine{LineType: COMMENT, Raw: raw, Trimmed: raw}
+}
@@ -0,0 +1,235 @@
+package txeh
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testBlockHosts = `127.0.0.1        localhost
+10.0.0.1         handwritten
+# BEGIN txeh:kubefwd
+127.1.27.1       svc-a
+127.1.27.2       svc-b # note
+# END txeh:kubefwd
+10.0.0.2         trailing
+`
+
+// Given a file with a managed block
+// When Block is called
+// Then it returns the address entries inside the markers.
+func TestBlock_ReadsEntries(t *testing.T) {
+	hosts := newRawHosts(t, testBlockHosts)
+
+	entries, ok := hosts.Block("kubefwd")
+	if !ok {
+		t.Fatal("block not found")
+	}
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %+v", entries)
+	}
+	if entries[1].Address != "127.1.27.2" || entries[1].Hostnames[0] != "svc-b" || entries[1].Comment != "note" {
+		t.Errorf("unexpected entry %+v", entries[1])
+	}
+
+	if _, ok := hosts.Block("missing"); ok {
+		t.Error("missing block reported as found")
+	}
+}
+
+// Given an existing block
+// When SetBlock replaces it
+// Then the block content changes in place and every other line is untouched.
+func TestSetBlock_ReplacesInPlace(t *testing.T) {
+	hosts := newRawHosts(t, testBlockHosts)
+
+	err := hosts.SetBlock("kubefwd", []BlockEntry{{Address: "127.1.27.9", Hostnames: []string{"SVC-C"}}})
+	if err != nil {
+		t.Fatalf("SetBlock() error: %v", err)
+	}
+
+	want := `127.0.0.1        localhost
+10.0.0.1         handwritten
+# BEGIN txeh:kubefwd
+127.1.27.9       svc-c
+# END txeh:kubefwd
+10.0.0.2         trailing
+`
+	if got := hosts.RenderHostsFile(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+// Given no block with the name
+// When SetBlock is called
+// Then the block is appended at the end of the file.
+func TestSetBlock_CreatesAtEnd(t *testing.T) {
+	hosts := newRawHosts(t, testHostsLocalhost)
+
+	err := hosts.SetBlock("myapp", []BlockEntry{{Address: "127.0.0.1", Hostnames: []string{"api.local", "web.local"}, Comment: "dev"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := hosts.RenderHostsFile()
+	want := "# BEGIN txeh:myapp\n127.0.0.1        api.local web.local # dev\n# END txeh:myapp\n"
+	if !strings.HasSuffix(got, want) {
+		t.Errorf("block not appended:\n%s", got)
+	}
+}
+
+// Given MaxHostsPerLine is set
+// When SetBlock receives an entry with more hostnames
+// Then the entry is split across lines.
+func TestSetBlock_RespectsMaxHostsPerLine(t *testing.T) {
+	raw := testHostsLocalhost
+	hosts, err := NewHosts(&HostsConfig{RawText: &raw, MaxHostsPerLine: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := hosts.SetBlock("b", []BlockEntry{{Address: "10.0.0.1", Hostnames: []string{"a", "b", "c"}}}); err != nil {
+		t.Fatal(err)
+	}
+	entries, _ := hosts.Block("b")
+	if len(entries) != 2 || len(entries[0].Hostnames) != 2 || len(entries[1].Hostnames) != 1 {
+		t.Errorf("unexpected split %+v", entries)
+	}
+}
+
+// Given invalid input
+// When SetBlock is called
+// Then it returns an error and nothing changes.
+func TestSetBlock_InvalidInput(t *testing.T) {
+	hosts := newRawHosts(t, testBlockHosts)
+	before := hosts.RenderHostsFile()
+
+	if err := hosts.SetBlock("bad name", nil); !errors.Is(err, ErrInvalidBlockName) {
+		t.Errorf("expected ErrInvalidBlockName, got %v", err)
+	}
+	if err := hosts.SetBlock("kubefwd", []BlockEntry{{Address: "nope", Hostnames: []string{"x"}}}); err == nil {
+		t.Error("expected invalid address error")
+	}
+	if err := hosts.SetBlock("kubefwd", []BlockEntry{{Address: "10.0.0.1"}}); err == nil {
+		t.Error("expected error for entry without hostnames")
+	}
+	if err := hosts.SetBlock("kubefwd", []BlockEntry{{Address: "10.0.0.1", Hostnames: []string{"a#b"}}}); err == nil {
+		t.Error("expected invalid hostname error")
+	}
+
+	if got := hosts.RenderHostsFile(); got != before {
+		t.Errorf("state changed after failed SetBlock:\n%s", got)
+	}
+}
+
+// Given a BEGIN marker without END
+// When SetBlock targets that block
+// Then it fails with ErrMalformedBlock.
+func TestSetBlock_Unterminated_ReturnsErrMalformedBlock(t *testing.T) {
+	hosts := newRawHosts(t, "127.0.0.1 localhost\n# BEGIN txeh:x\n10.0.0.1 a\n")
+
+	if err := hosts.SetBlock("x", nil); !errors.Is(err, ErrMalformedBlock) {
+		t.Errorf("expected ErrMalformedBlock, got %v", err)
+	}
+	if _, ok := hosts.Block("x"); ok {
+		t.Error("unterminated block reported as found")
+	}
+	if hosts.RemoveBlock("x") {
+		t.Error("unterminated block should not be removed")
+	}
+}
+
+// Given an existing block
+// When RemoveBlock is called
+// Then markers and content are removed and other lines stay.
+func TestRemoveBlock(t *testing.T) {
+	hosts := newRawHosts(t, testBlockHosts)
+
+	if !hosts.RemoveBlock("kubefwd") {
+		t.Fatal("RemoveBlock returned false")
+	}
+	want := "127.0.0.1        localhost\n10.0.0.1         handwritten\n10.0.0.2         trailing\n"
+	if got := hosts.RenderHostsFile(); got != want {
+		t.Errorf("got:\n%s", got)
+	}
+	if hosts.RemoveBlock("kubefwd") {
+		t.Error("second RemoveBlock should report false")
+	}
+}
+
+// Given a block with an address line matching a new AddHost call
+// When AddHost adds to that address
+// Then the host goes on a line outside the block.
+func TestAddHost_SkipsBlockLines(t *testing.T) {
+	hosts := newRawHosts(t, testBlockHosts)
+
+	hosts.AddHost("127.1.27.1", "outside")
+
+	entries, _ := hosts.Block("kubefwd")
+	for _, e := range entries {
+		for _, hn := range e.Hostnames {
+			if hn == "outside" {
+				t.Fatal("AddHost appended into a managed block")
+			}
+		}
+	}
+	if !strings.HasSuffix(hosts.RenderHostsFile(), "127.1.27.1       outside\n") {
+		t.Errorf("expected a new line at the end:\n%s", hosts.RenderHostsFile())
+	}
+}
+
+// Given a hostname already inside a managed block
+// When AddHost moves it to another address of the same family
+// Then the block is unchanged and the host is added outside it.
+func TestAddHost_MoveKeepsBlockLines(t *testing.T) {
+	hosts := newRawHosts(t, testBlockHosts)
+	before, _ := hosts.Block("kubefwd")
+
+	hosts.AddHost("10.0.0.9", "svc-a")
+
+	if after, _ := hosts.Block("kubefwd"); !reflect.DeepEqual(after, before) {
+		t.Errorf("Block() = %+v, want %+v", after, before)
+	}
+	if !strings.HasSuffix(hosts.RenderHostsFile(), "10.0.0.9         svc-a\n") {
+		t.Errorf("expected a new line at the end:\n%s", hosts.RenderHostsFile())
+	}
+}
+
+// Given a saved block and another tool that edited the file afterwards
+// When SetBlock is replayed by SaveMerge
+// Then the block is replaced in the merged file.
+func TestSetBlock_SaveMerge(t *testing.T) {
+	path := writeHostsFile(t, testBlockHosts)
+
+	hosts, err := NewHosts(&HostsConfig{ReadFilePath: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := hosts.SetBlock("kubefwd", []BlockEntry{{Address: "127.1.27.5", Hostnames: []string{"merged"}}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(path, []byte(testBlockHosts+"10.0.0.9 theirs\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := hosts.SaveMerge(); err != nil {
+		t.Fatal(err)
+	}
+
+	got := readHostsFile(t, path)
+	if !strings.Contains(got, "theirs") || !strings.Contains(got, "merged") || strings.Contains(got, "svc-a") {
+		t.Errorf("unexpected merge result:\n%s", got)
+	}
+}
+
+// newRawHosts returns a Hosts instance parsed from raw.
+func newRawHosts(t *testing.T, raw string) *Hosts {
+	t.Helper()
+	hosts, err := NewHosts(&HostsConfig{RawText: &raw})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hosts
+}
@@ -0,0 +1,184 @@
+package txeh
+
+import (
+	"errors"
+	"time"
+)
+
+// coalescer holds the state of saves deferred by HostsConfig.SaveInterval.
+// Its fields are guarded by Hosts.mu.
+type coalescer struct {
+	timer *time.Timer
+	// gen identifies the current timer; a callback from a stopped timer
+	// that already fired sees a different value and does nothing.
+	gen uint64
+	// dirty is set when Save was called since the last write.
+	dirty bool
+	// unflushed is set when a background write skipped the auto flush.
+	unflushed bool
+	// holdFlush makes autoFlushLocked record the flush instead of running it.
+	holdFlush bool
+	// err is the first error of a background write or flush not yet
+	// returned by Save, Sync or Close.
+	err    error
+	closed bool
+}
+
+// deferSaveLocked marks the hosts dirty and schedules the write. It returns
+// the pending background error, if any. Must be called with the lock held.
+func (h *Hosts) deferSaveLocked() error {
+	c := &h.coalesce
+	c.dirty = true
+	if c.timer == nil {
+		h.scheduleCoalescedLocked()
+	}
+
+	err := c.err
+	c.err = nil
+	return err
+}
+
+// scheduleCoalescedLocked starts the timer for the next background write or
+// flush. Must be called with the lock held.
+func (h *Hosts) scheduleCoalescedLocked() {
+	c := &h.coalesce
+	c.gen++
+	gen := c.gen
+	c.timer = time.AfterFunc(h.SaveInterval, func() { h.coalescedTick(gen) })
+}
+
+// coalescedTick runs when SaveInterval has passed since the timer started.
+// Changes saved since the last write are written, without flushing, and the
+// timer restarts; once a whole interval passes without a Save the deferred
+// flush runs.
+func (h *Hosts) coalescedTick(gen uint64) {
+	// Wait for the cross-process lock before taking h.mu, so callers on this
+	// instance don't stall for up to LockTimeout behind a background write.
+	fl, lockErr := h.coalescedFileLock(gen)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	c := &h.coalesce
+	if gen != c.gen || c.closed {
+		_ = fl.Unlock()
+		return
+	}
+	c.timer = nil
+
+	switch {
+	case c.dirty:
+		c.dirty = false
+		err := lockErr
+		if err == nil {
+			switch {
+			case fl == nil:
+			case h.fileLock == nil:
+				// saveLocked releases it after the write.
+				h.fileLock = fl
+			default:
+				_ = fl.Unlock()
+			}
+			c.holdFlush = true
+			err = h.saveLocked()
+			c.holdFlush = false
+		}
+		h.keepCoalescedErrLocked(err)
+		h.scheduleCoalescedLocked()
+	case c.unflushed:
+		c.unflushed = false
+		h.keepCoalescedErrLocked(h.autoFlushLocked())
+	}
+}
+
+// coalescedFileLock takes the write path lock for the background write of
+// timer gen when LockTimeout asks for one and this instance doesn't hold it
+// already. It returns nil when no lock is needed.
+func (h *Hosts) coalescedFileLock(gen uint64) (*FileLock, error) {
+	h.mu.Lock()
+	c := &h.coalesce
+	need := gen == c.gen && !c.closed && c.dirty &&
+		h.LockTimeout > 0 && h.fileLock == nil && h.customStore() == nil
+	path, timeout := h.WriteFilePath, h.LockTimeout
+	h.mu.Unlock()
+
+	if !need {
+		return nil, nil
+	}
+	return LockHostsFile(path, timeout)
+}
+
+// coalescedWrittenLocked records that the hosts were just written to their
+// configured target outside the coalescer (Update, SaveAs, SaveMerge), so
+// the next tick doesn't write the same content again. Must be called with
+// the lock held.
+func (h *Hosts) coalescedWrittenLocked() {
+	h.coalesce.dirty = false
+	h.coalesce.unflushed = false
+}
+
+// keepCoalescedErrLocked records a background error for the next Save, Sync
+// or Close. Must be called with the lock held.
+func (h *Hosts) keepCoalescedErrLocked(err error) {
+	if err != nil && h.coalesce.err == nil {
+		h.coalesce.err = err
+	}
+}
+
+// Sync writes changes whose save was deferred by SaveInterval and runs a
+// pending auto flush now. It returns the error of that write or flush
+// joined with any earlier background error. Without pending changes Sync
+// does nothing.
+func (h *Hosts) Sync() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.syncLocked()
+}
+
+// syncLocked is Sync with the lock held.
+func (h *Hosts) syncLocked() error {
+	c := &h.coalesce
+	if c.timer != nil {
+		c.timer.Stop()
+		c.timer = nil
+	}
+	c.gen++
+
+	err := c.err
+	c.err = nil
+
+	switch {
+	case c.dirty:
+		c.dirty, c.unflushed = false, false
+		err = joinErrors(err, h.saveLocked())
+	case c.unflushed:
+		c.unflushed = false
+		err = joinErrors(err, h.autoFlushLocked())
+	}
+	return err
+}
+
+// joinErrors is errors.Join, except that a single non-nil error is returned
+// as is so callers can type-assert it.
+func joinErrors(earlier, err error) error {
+	if earlier == nil {
+		return err
+	}
+	if err == nil {
+		return earlier
+	}
+	return errors.Join(earlier, err)
+}
+
+// Close writes and flushes pending changes like Sync and stops coalescing:
+// a Save after Close writes immediately. Close does not release a lock
+// taken with LockTimeout (see ReleaseLock).
+func (h *Hosts) Close() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	err := h.syncLocked()
+	h.coalesce.closed = true
+	return err
+}
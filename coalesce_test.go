@@ -0,0 +1,319 @@
+package txeh
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// countingStore is a MemoryStore that counts writes.
+type countingStore struct {
+	MemoryStore
+	writes atomic.Int32
+}
+
+func (s *countingStore) Store(data []byte) error {
+	s.writes.Add(1)
+	return s.MemoryStore.Store(data)
+}
+
+// newCoalescedHosts returns hosts saving to a counting store every interval
+// and the number of flushes run so far.
+func newCoalescedHosts(t *testing.T, interval time.Duration) (*Hosts, *countingStore, *atomic.Int32) {
+	t.Helper()
+	store := &countingStore{}
+	_ = store.MemoryStore.Store([]byte(testHostsLocalhost))
+	flushes := &atomic.Int32{}
+
+	hosts, err := NewHosts(&HostsConfig{
+		Store:        store,
+		AutoFlush:    true,
+		Flusher:      FlusherFunc(func() error { flushes.Add(1); return nil }),
+		SaveInterval: interval,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = hosts.Close() })
+	return hosts, store, flushes
+}
+
+// waitFor polls cond until it holds or the deadline passes.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// Given SaveInterval and AutoFlush
+// When a hundred hosts are added and saved in a burst
+// Then the file is written once, after the interval, and flushed once.
+func TestSaveInterval_CoalescesBurst(t *testing.T) {
+	t.Parallel()
+
+	hosts, store, flushes := newCoalescedHosts(t, 50*time.Millisecond)
+
+	for i := range 100 {
+		hosts.AddHost("10.0.0.1", fmt.Sprintf("svc-%d.local", i))
+		if err := hosts.Save(); err != nil {
+			t.Fatalf("Save() error = %v", err)
+		}
+	}
+	if store.writes.Load() != 0 {
+		t.Errorf("wrote %d times during the burst, want 0", store.writes.Load())
+	}
+
+	waitFor(t, "flush", func() bool { return flushes.Load() > 0 })
+	if n := store.writes.Load(); n != 1 {
+		t.Errorf("wrote %d times, want 1", n)
+	}
+	if !strings.Contains(store.String(), "svc-99.local") {
+		t.Errorf("store missing last host:\n%s", store.String())
+	}
+
+	time.Sleep(150 * time.Millisecond)
+	if n := flushes.Load(); n != 1 {
+		t.Errorf("flushed %d times, want 1", n)
+	}
+}
+
+// Given SaveInterval
+// When saves keep arriving for several intervals
+// Then writes happen at most once per interval and the flush waits for
+// the burst to end.
+func TestSaveInterval_WritesAtMostOncePerInterval(t *testing.T) {
+	t.Parallel()
+
+	const interval = 40 * time.Millisecond
+	hosts, store, flushes := newCoalescedHosts(t, interval)
+
+	start := time.Now()
+	for i := 0; time.Since(start) < 5*interval; i++ {
+		hosts.AddHost("10.0.0.2", fmt.Sprintf("burst-%d.local", i))
+		if err := hosts.Save(); err != nil {
+			t.Fatalf("Save() error = %v", err)
+		}
+		time.Sleep(2 * time.Millisecond)
+	}
+	elapsed := time.Since(start)
+	if n := flushes.Load(); n != 0 {
+		t.Errorf("flushed %d times during the burst, want 0", n)
+	}
+
+	waitFor(t, "flush", func() bool { return flushes.Load() > 0 })
+	if n, limit := store.writes.Load(), int32(elapsed/interval)+2; n < 2 || n > limit {
+		t.Errorf("wrote %d times in %s, want between 2 and %d", n, elapsed, limit)
+	}
+}
+
+// Given a deferred save
+// When Sync is called
+// Then the file is written and flushed immediately, and nothing runs later.
+func TestSaveInterval_SyncWritesNow(t *testing.T) {
+	t.Parallel()
+
+	hosts, store, flushes := newCoalescedHosts(t, time.Hour)
+
+	hosts.AddHost("10.0.0.3", "sync.local")
+	if err := hosts.Save(); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if err := hosts.Sync(); err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+	if store.writes.Load() != 1 || flushes.Load() != 1 || !strings.Contains(store.String(), "sync.local") {
+		t.Errorf("after Sync: %d writes, %d flushes, store:\n%s", store.writes.Load(), flushes.Load(), store.String())
+	}
+
+	if err := hosts.Sync(); err != nil {
+		t.Fatalf("second Sync() error = %v", err)
+	}
+	if store.writes.Load() != 1 || flushes.Load() != 1 {
+		t.Errorf("Sync without changes wrote %d times and flushed %d times", store.writes.Load(), flushes.Load())
+	}
+}
+
+// Given a background write that skipped its flush
+// When Close is called
+// Then the flush runs, and later saves write immediately.
+func TestSaveInterval_CloseFlushesAndStopsCoalescing(t *testing.T) {
+	t.Parallel()
+
+	hosts, store, flushes := newCoalescedHosts(t, 20*time.Millisecond)
+
+	hosts.AddHost("10.0.0.4", "close.local")
+	if err := hosts.Save(); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	waitFor(t, "background write", func() bool { return store.writes.Load() == 1 })
+
+	if err := hosts.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if n := flushes.Load(); n != 1 {
+		t.Errorf("flushed %d times, want 1", n)
+	}
+
+	hosts.AddHost("10.0.0.4", "after.local")
+	if err := hosts.Save(); err != nil {
+		t.Fatalf("Save() after Close error = %v", err)
+	}
+	if store.writes.Load() != 2 || !strings.Contains(store.String(), "after.local") {
+		t.Errorf("Save after Close did not write immediately:\n%s", store.String())
+	}
+}
+
+// Given a read-only store
+// When a deferred save fails in the background
+// Then the next Save returns the error once.
+func TestSaveInterval_BackgroundErrorReturnedBySave(t *testing.T) {
+	t.Parallel()
+
+	hosts, err := NewHosts(&HostsConfig{
+		Store:        NewFSStore(fstest.MapFS{"hosts": {Data: []byte(testHostsLocalhost)}}, "hosts"),
+		SaveInterval: 10 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = hosts.Close() }()
+
+	if err := hosts.Save(); err != nil {
+		t.Fatalf("first Save() error = %v", err)
+	}
+	waitFor(t, "background write", func() bool {
+		hosts.mu.Lock()
+		defer hosts.mu.Unlock()
+		return hosts.coalesce.err != nil
+	})
+
+	if err := hosts.Save(); !errors.Is(err, ErrReadOnlyStore) {
+		t.Errorf("Save() error = %v, want ErrReadOnlyStore", err)
+	}
+	if err := hosts.Sync(); !errors.Is(err, ErrReadOnlyStore) {
+		t.Errorf("Sync() error = %v, want the pending write to fail again", err)
+	}
+	if err := hosts.Sync(); err != nil {
+		t.Errorf("Sync() without changes error = %v", err)
+	}
+}
+
+// Given hosts without SaveInterval
+// When Sync and Close are called
+// Then they do nothing.
+func TestSync_WithoutSaveInterval(t *testing.T) {
+	t.Parallel()
+
+	store := &countingStore{}
+	hosts, err := NewHosts(&HostsConfig{Store: store})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := hosts.Sync(); err != nil {
+		t.Errorf("Sync() error = %v", err)
+	}
+	if err := hosts.Close(); err != nil {
+		t.Errorf("Close() error = %v", err)
+	}
+	if store.writes.Load() != 0 {
+		t.Errorf("wrote %d times, want 0", store.writes.Load())
+	}
+}
+
+// Given a deferred save
+// When Update, SaveAs or SaveMerge writes immediately
+// Then the next tick doesn't write the same content again.
+func TestSaveInterval_ImmediateWriteClearsDeferredSave(t *testing.T) {
+	t.Parallel()
+
+	writes := map[string]func(h *Hosts) error{
+		"Update":    func(h *Hosts) error { return h.Update(func(*Tx) error { return nil }) },
+		"SaveMerge": func(h *Hosts) error { return h.SaveMerge() },
+	}
+	for name, write := range writes {
+		hosts, store, flushes := newCoalescedHosts(t, 20*time.Millisecond)
+
+		hosts.AddHost("10.0.0.5", "now.local")
+		if err := hosts.Save(); err != nil {
+			t.Fatalf("%s: Save() error = %v", name, err)
+		}
+		if err := write(hosts); err != nil {
+			t.Fatalf("%s: error = %v", name, err)
+		}
+
+		time.Sleep(100 * time.Millisecond)
+		if store.writes.Load() != 1 || flushes.Load() != 1 {
+			t.Errorf("%s: %d writes and %d flushes, want 1 each", name, store.writes.Load(), flushes.Load())
+		}
+	}
+
+	path := writeHostsFile(t, testHostsLocalhost)
+	hosts, err := NewHosts(&HostsConfig{ReadFilePath: path, SaveInterval: 20 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = hosts.Close() }()
+	hosts.AddHost("10.0.0.5", "now.local")
+	if err := hosts.Save(); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if err := hosts.SaveAs(path); err != nil {
+		t.Fatalf("SaveAs() error = %v", err)
+	}
+	hosts.mu.Lock()
+	dirty := hosts.coalesce.dirty
+	hosts.mu.Unlock()
+	if dirty {
+		t.Error("SaveAs to the write path left the deferred save pending")
+	}
+}
+
+// Given a background write waiting for a lock held by another process
+// When the instance is used meanwhile
+// Then callers aren't blocked, and the write happens once the lock is free.
+func TestSaveInterval_BackgroundWriteWaitsForLockWithoutBlocking(t *testing.T) {
+	t.Parallel()
+
+	path := writeHostsFile(t, testHostsLocalhost)
+	hosts, err := NewHosts(&HostsConfig{ReadFilePath: path, LockTimeout: 5 * time.Second, SaveInterval: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = hosts.Close() }()
+	if err := hosts.ReleaseLock(); err != nil {
+		t.Fatal(err)
+	}
+
+	other, err := LockHostsFile(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hosts.AddHost("10.0.0.6", "locked.local")
+	if err := hosts.Save(); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	start := time.Now()
+	hosts.ListHostsByIP("10.0.0.6")
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("ListHostsByIP waited %s for the background write", elapsed)
+	}
+	if strings.Contains(readHostsFile(t, path), "locked.local") {
+		t.Fatal("written while another process held the lock")
+	}
+
+	if err := other.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "background write", func() bool { return strings.Contains(readHostsFile(t, path), "locked.local") })
+}
@@ -0,0 +1,171 @@
+package txeh
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ErrConcurrentModification is returned by Save and SaveAs when the hosts file
+// was changed on disk by someone else after this instance parsed it. Check for
+// it with errors.Is, then either Reload and redo the change or call SaveMerge.
+var ErrConcurrentModification = errors.New("hosts file was modified on disk since it was loaded")
+
+// fileSnapshot records what a hosts file looked like when it was parsed.
+type fileSnapshot struct {
+	hash    [sha256.Size]byte
+	modTime time.Time
+}
+
+// newFileSnapshot describes data read from (or written to) path.
+func newFileSnapshot(path string, data []byte) *fileSnapshot {
+	snap := &fileSnapshot{hash: sha256.Sum256(data)}
+	if info, err := os.Stat(filepath.Clean(path)); err == nil {
+		snap.modTime = info.ModTime()
+	}
+	return snap
+}
+
+// mutate applies op to the in-memory state under the lock and records it so
+// SaveMerge can replay it on top of a freshly read file. Every public mutation
+// goes through mutate. Hosts from RawText or Reader can't SaveMerge and
+// record nothing, so a long-lived in-memory instance doesn't grow.
+func (h *Hosts) mutate(op func(*Hosts)) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	op(h)
+	if h.inMemorySource() == "" {
+		h.pending = append(h.pending, op)
+	}
+}
+
+// mutateErr is mutate for operations that can fail. An op that returns an
+// error must leave the state unchanged; it is not recorded. When replayed by
+// SaveMerge an error means the op no longer applies and it is skipped.
+func (h *Hosts) mutateErr(op func(*Hosts) error) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if err := op(h); err != nil {
+		return err
+	}
+	if h.inMemorySource() == "" {
+		h.pending = append(h.pending, func(x *Hosts) { _ = op(x) })
+	}
+
+	return nil
+}
+
+// loadLocked reads and parses ReadFilePath (or the configured Store),
+// replacing the in-memory state and recording a snapshot of a file's
+// content. Pending mutations are discarded. Must be called with the lock held.
+func (h *Hosts) loadLocked() error {
+	data, err := h.backendLocked().Load()
+	if err != nil {
+		return err
+	}
+
+	if err := h.parseLocked(bytes.NewReader(data)); err != nil {
+		return err
+	}
+
+	h.loaded = nil
+	if h.customStore() == nil {
+		h.loaded = newFileSnapshot(h.ReadFilePath, data)
+	}
+	h.pending = nil
+
+	return nil
+}
+
+// checkUnmodifiedLocked returns an error wrapping ErrConcurrentModification
+// when fileName is the file this instance was loaded from and its content no
+// longer matches the snapshot taken at load time. The content hash decides;
+// the modification time is reported for context. Must be called with the lock held.
+func (h *Hosts) checkUnmodifiedLocked(fileName string) error {
+	if h.loaded == nil || !sameFile(fileName, h.ReadFilePath) {
+		return nil
+	}
+
+	data, err := os.ReadFile(filepath.Clean(h.ReadFilePath))
+	if errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("%s was removed: %w", h.ReadFilePath, ErrConcurrentModification)
+	}
+	if err != nil {
+		return fmt.Errorf("read hosts file %s: %w", h.ReadFilePath, err)
+	}
+
+	if sha256.Sum256(data) == h.loaded.hash {
+		return nil
+	}
+
+	current := newFileSnapshot(h.ReadFilePath, data)
+	return fmt.Errorf("%s (loaded with mtime %s, now %s): %w",
+		h.ReadFilePath,
+		h.loaded.modTime.Format(time.RFC3339Nano),
+		current.modTime.Format(time.RFC3339Nano),
+		ErrConcurrentModification)
+}
+
+// recordWriteLocked updates the load snapshot after data was written to
+// fileName. Writing the file this instance was loaded from makes the written
+// content the new baseline and clears the pending mutations. Must be called
+// with the lock held.
+func (h *Hosts) recordWriteLocked(fileName string, data []byte) {
+	if h.loaded == nil || !sameFile(fileName, h.ReadFilePath) {
+		return
+	}
+
+	h.loaded = newFileSnapshot(h.ReadFilePath, data)
+	h.pending = nil
+}
+
+// SaveMerge re-reads the hosts file (or the configured Store), replays every mutation made on this
+// instance since it was loaded (or last saved) on top of the current content,
+// and writes the result to the configured write path. Use it instead of Save
+// when other tools may have edited the file in the meantime: their changes are
+// kept rather than overwritten, and the in-memory state reflects the merged
+// file afterwards.
+//
+// With LockTimeout set, the cross-process lock is held across the re-read and
+// the write, so no other txeh user can slip in between.
+func (h *Hosts) SaveMerge() error {
+	if src := h.inMemorySource(); src != "" {
+		return fmt.Errorf("cannot call SaveMerge with %s. Use RenderHostsFile to return a string", src)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	s := h.customStore()
+	release := func() error { return nil }
+	if s == nil {
+		var err error
+		if release, err = h.lockForWriteLocked(h.WriteFilePath); err != nil {
+			return err
+		}
+	}
+	defer func() { _ = release() }()
+
+	pending := h.pending
+	if err := h.loadLocked(); err != nil {
+		return err
+	}
+	for _, op := range pending {
+		op(h)
+	}
+	// Keep the operations until the write succeeds so a failed SaveMerge can
+	// be retried.
+	h.pending = pending
+
+	if s != nil {
+		return h.saveToStoreLocked(s)
+	}
+	return h.writeLocked(h.WriteFilePath)
+}
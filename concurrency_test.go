@@ -0,0 +1,242 @@
+package txeh
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeHostsFile writes content to a hosts file in a fresh temp dir.
+func writeHostsFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "hosts")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// readHostsFile returns the content of path.
+func readHostsFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// Given a hosts file changed by another tool after NewHosts
+// When Save is called
+// Then it fails with ErrConcurrentModification and leaves the other tool's content in place.
+func TestSave_ExternalModification_ReturnsErrConcurrentModification(t *testing.T) {
+	path := writeHostsFile(t, testHostsLocalhost)
+
+	hosts, err := NewHosts(&HostsConfig{ReadFilePath: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hosts.AddHost(testIPv4Alt, "mine")
+
+	external := testHostsLocalhost + "10.0.0.9 theirs\n"
+	if err := os.WriteFile(path, []byte(external), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	err = hosts.Save()
+	if !errors.Is(err, ErrConcurrentModification) {
+		t.Fatalf("expected ErrConcurrentModification, got %v", err)
+	}
+	if got := readHostsFile(t, path); got != external {
+		t.Errorf("file was overwritten: %q", got)
+	}
+}
+
+// Given an unchanged hosts file
+// When Save is called twice with changes in between
+// Then both saves succeed because each write becomes the new baseline.
+func TestSave_Unmodified_SucceedsRepeatedly(t *testing.T) {
+	path := writeHostsFile(t, testHostsLocalhost)
+
+	hosts, err := NewHosts(&HostsConfig{ReadFilePath: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hosts.AddHost(testIPv4Alt, "first")
+	if err := hosts.Save(); err != nil {
+		t.Fatalf("first Save() error: %v", err)
+	}
+	hosts.AddHost(testIPv4Alt, "second")
+	if err := hosts.Save(); err != nil {
+		t.Fatalf("second Save() error: %v", err)
+	}
+
+	got := readHostsFile(t, path)
+	if !strings.Contains(got, "first") || !strings.Contains(got, "second") {
+		t.Errorf("missing hosts in %q", got)
+	}
+}
+
+// Given a hosts file rewritten with identical content (only the mtime changed)
+// When Save is called
+// Then it succeeds, since the content hash decides.
+func TestSave_TouchedButIdentical_Succeeds(t *testing.T) {
+	path := writeHostsFile(t, testHostsLocalhost)
+
+	hosts, err := NewHosts(&HostsConfig{ReadFilePath: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(testHostsLocalhost), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := hosts.Save(); err != nil {
+		t.Errorf("Save() error: %v", err)
+	}
+}
+
+// Given a hosts file deleted after load
+// When Save is called
+// Then it fails with ErrConcurrentModification.
+func TestSave_FileRemoved_ReturnsErrConcurrentModification(t *testing.T) {
+	path := writeHostsFile(t, testHostsLocalhost)
+
+	hosts, err := NewHosts(&HostsConfig{ReadFilePath: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = os.Remove(path)
+
+	if err := hosts.Save(); !errors.Is(err, ErrConcurrentModification) {
+		t.Fatalf("expected ErrConcurrentModification, got %v", err)
+	}
+}
+
+// Given a concurrent modification
+// When Reload is called before saving again
+// Then Save succeeds against the new baseline.
+func TestSave_AfterReload_Succeeds(t *testing.T) {
+	path := writeHostsFile(t, testHostsLocalhost)
+
+	hosts, err := NewHosts(&HostsConfig{ReadFilePath: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(testHostsLocalhost+"10.0.0.9 theirs\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := hosts.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	hosts.AddHost(testIPv4Alt, "mine")
+
+	if err := hosts.Save(); err != nil {
+		t.Fatalf("Save() after Reload error: %v", err)
+	}
+	got := readHostsFile(t, path)
+	if !strings.Contains(got, "theirs") || !strings.Contains(got, "mine") {
+		t.Errorf("expected both entries, got %q", got)
+	}
+}
+
+// Given a concurrent modification of the loaded file
+// When SaveAs targets a different file
+// Then the check does not apply and the write succeeds.
+func TestSaveAs_OtherPath_IgnoresModification(t *testing.T) {
+	path := writeHostsFile(t, testHostsLocalhost)
+
+	hosts, err := NewHosts(&HostsConfig{ReadFilePath: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("# changed\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	other := filepath.Join(t.TempDir(), "hosts.copy")
+	if err := hosts.SaveAs(other); err != nil {
+		t.Errorf("SaveAs(other) error: %v", err)
+	}
+}
+
+// Given another tool added and removed entries after load
+// When SaveMerge is called
+// Then the result contains the other tool's changes plus this instance's adds and removes.
+func TestSaveMerge_ReplaysPendingOperations(t *testing.T) {
+	path := writeHostsFile(t, "127.0.0.1 localhost\n10.0.0.1 old-a old-b\n")
+
+	hosts, err := NewHosts(&HostsConfig{ReadFilePath: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hosts.AddHost(testIPv4Alt, "mine")
+	hosts.RemoveHost("old-a")
+
+	external := "127.0.0.1 localhost\n10.0.0.1 old-a old-b\n10.0.0.9 theirs\n"
+	if err := os.WriteFile(path, []byte(external), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := hosts.SaveMerge(); err != nil {
+		t.Fatalf("SaveMerge() error: %v", err)
+	}
+
+	got := readHostsFile(t, path)
+	for _, want := range []string{"theirs", "mine", "old-b"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("merged file missing %q: %q", want, got)
+		}
+	}
+	if strings.Contains(got, "old-a") {
+		t.Errorf("merged file still contains removed host: %q", got)
+	}
+	if hosts.ListHostsByIP("10.0.0.9") == nil {
+		t.Error("in-memory state should reflect the merged file")
+	}
+
+	// The merged write is the new baseline.
+	hosts.AddHost(testIPv4Alt, "after")
+	if err := hosts.Save(); err != nil {
+		t.Errorf("Save() after SaveMerge error: %v", err)
+	}
+}
+
+// Given a RawText instance
+// When SaveMerge is called
+// Then it returns an error.
+func TestSaveMerge_RawText_ReturnsError(t *testing.T) {
+	raw := testHostsLocalhost
+	hosts, err := NewHosts(&HostsConfig{RawText: &raw})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := hosts.SaveMerge(); err == nil {
+		t.Error("SaveMerge with RawText should return error")
+	}
+}
+
+// Given a RawText instance, which can't SaveMerge
+// When it is mutated many times, directly and in a transaction
+// Then no operations are recorded for replay.
+func TestMutate_RawText_RecordsNoPending(t *testing.T) {
+	hosts := newRawHosts(t, testHostsLocalhost)
+	for i := range 100 {
+		hosts.AddHost("10.0.0.1", fmt.Sprintf("svc-%d.local", i))
+		hosts.RemoveHost(fmt.Sprintf("svc-%d.local", i))
+		if err := hosts.AddHostWithOptions("10.0.0.2", "app.local", AddOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := hosts.Update(func(tx *Tx) error { tx.AddHost("10.0.0.3", "tx.local"); return nil }); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := len(hosts.pending); n != 0 {
+		t.Errorf("recorded %d pending operations, want 0", n)
+	}
+}
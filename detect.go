@@ -0,0 +1,322 @@
+package txeh
+
+import (
+	"bufio"
+	"fmt"
+	"net/netip"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Names of the cache layers reported by DetectResolvers.
+const (
+	ResolverSystemd       = "systemd-resolved"
+	ResolverMDNSResponder = "mDNSResponder"
+	ResolverDNSClient     = "DNS Client"
+	ResolverNSCD          = "nscd"
+	ResolverDnsmasq       = "dnsmasq"
+	ResolverUnbound       = "unbound"
+	ResolverUnknownLocal  = "local resolver"
+)
+
+// ResolverLayer is a DNS cache found by DetectResolvers.
+type ResolverLayer struct {
+	Name string `json:"name"`
+	// Running is set when the daemon's process or runtime state was found.
+	Running bool `json:"running"`
+	// PIDs are the process ids found under /proc.
+	PIDs []int `json:"pids,omitempty"`
+	// Binary is the control program found on PATH, if any.
+	Binary string `json:"binary,omitempty"`
+	// Flushable reports whether txeh can flush this layer; Flusher is then
+	// set to a flusher for it.
+	Flushable bool    `json:"flushable"`
+	Flusher   Flusher `json:"-"`
+	// Detail explains what was found, or why the layer can't be flushed.
+	Detail string `json:"detail,omitempty"`
+}
+
+// String formats the layer as "dnsmasq: running, flushable (pid file /run/dnsmasq/dnsmasq.pid)".
+func (l ResolverLayer) String() string {
+	state := "not running"
+	if l.Running {
+		state = "running"
+	}
+	flush := "not flushable"
+	if l.Flushable {
+		flush = "flushable"
+	}
+	s := fmt.Sprintf("%s: %s, %s", l.Name, state, flush)
+	if l.Detail != "" {
+		s += " (" + l.Detail + ")"
+	}
+	return s
+}
+
+// ResolverReport describes the DNS caching layers in front of the hosts file.
+type ResolverReport struct {
+	Platform string `json:"platform"`
+	// Layers are the caches that are running or installed: the platform
+	// cache first, then nscd, dnsmasq and unbound.
+	Layers []ResolverLayer `json:"layers"`
+	// Nameservers are the nameserver entries of /etc/resolv.conf.
+	Nameservers []string `json:"nameservers,omitempty"`
+}
+
+// Flusher returns a ChainFlusher of the flushable running layers, suitable
+// for HostsConfig.Flusher. The chain is empty when nothing needs flushing.
+func (r *ResolverReport) Flusher() ChainFlusher {
+	var chain ChainFlusher
+	for _, l := range r.Layers {
+		if l.Running && l.Flushable {
+			chain = append(chain, l.Flusher)
+		}
+	}
+	return chain
+}
+
+// DetectResolvers inspects the system for DNS caches that can serve stale
+// answers after the hosts file changes: the platform resolver FlushDNSCache
+// flushes, nscd, dnsmasq and unbound. It looks for their control programs on
+// PATH, their processes under /proc and systemd-resolved's state in
+// /run/systemd/resolve, and reads the nameservers of /etc/resolv.conf. Probes
+// that fail (no /proc on macOS, an unreadable file) are skipped, so the report
+// may be incomplete but DetectResolvers never fails.
+func DetectResolvers() *ResolverReport {
+	return defaultResolverEnv.detect()
+}
+
+// resolverDaemon describes how to find a DNS cache.
+type resolverDaemon struct {
+	name string
+	// process is the daemon's name in /proc/<pid>/comm, which the kernel
+	// truncates to 15 bytes.
+	process string
+	// binaries are the control programs, in order of preference.
+	binaries []string
+	// stateDir exists while the daemon runs.
+	stateDir string
+}
+
+// resolverEnv holds the system locations DetectResolvers reads, so tests
+// can point them at a fake system.
+type resolverEnv struct {
+	lookPath    func(string) (string, error)
+	procDir     string
+	resolvConf  string
+	system      resolverDaemon
+	dnsmasqPIDs []string
+}
+
+var defaultResolverEnv = resolverEnv{
+	lookPath:   exec.LookPath,
+	procDir:    "/proc",
+	resolvConf: "/etc/resolv.conf",
+	system:     systemResolver,
+	dnsmasqPIDs: []string{
+		"/run/dnsmasq/dnsmasq.pid",
+		"/var/run/dnsmasq/dnsmasq.pid",
+		"/run/dnsmasq.pid",
+		"/var/run/dnsmasq.pid",
+	},
+}
+
+var (
+	nscdDaemon    = resolverDaemon{name: ResolverNSCD, process: "nscd", binaries: []string{"nscd"}}
+	dnsmasqDaemon = resolverDaemon{name: ResolverDnsmasq, process: "dnsmasq"}
+	unboundDaemon = resolverDaemon{name: ResolverUnbound, process: "unbound", binaries: []string{"unbound-control"}}
+)
+
+// detect builds the report.
+func (env resolverEnv) detect() *ResolverReport {
+	report := &ResolverReport{Platform: runtime.GOOS}
+	procs := env.processes()
+	report.Nameservers = env.nameservers()
+
+	if env.system.name != "" {
+		l := env.layer(env.system, procs)
+		// Without /proc the platform cache is assumed to run wherever its
+		// flush command is installed.
+		l.Running = l.Running || (env.system.process == "" && l.Binary != "")
+		if l.Running && l.Binary != "" {
+			l.Flushable, l.Flusher = true, SystemFlusher{}
+		}
+		if l.Name == ResolverSystemd && slices.Contains(report.Nameservers, "127.0.0.53") {
+			l.Detail = "stub listener 127.0.0.53 in resolv.conf"
+		}
+		if l.Running || l.Binary != "" {
+			report.Layers = append(report.Layers, l)
+		}
+	}
+
+	nscd := env.layer(nscdDaemon, procs)
+	if nscd.Running && nscd.Binary != "" {
+		nscd.Flushable, nscd.Flusher = true, NSCDFlusher{}
+	}
+
+	dnsmasq := env.layer(dnsmasqDaemon, procs)
+	if dnsmasq.Running {
+		if pidFile := env.dnsmasqPIDFile(dnsmasq.PIDs); pidFile != "" {
+			dnsmasq.Flushable, dnsmasq.Flusher = true, DnsmasqFlusher{PIDFile: pidFile}
+			dnsmasq.Detail = "pid file " + pidFile
+		} else {
+			dnsmasq.Detail = "no pid file found"
+		}
+	}
+
+	unbound := env.layer(unboundDaemon, procs)
+	if unbound.Running {
+		if unbound.Binary != "" {
+			unbound.Flushable, unbound.Flusher = true, UnboundFlusher{}
+			unbound.Detail = "requires remote-control to be enabled"
+		} else {
+			unbound.Detail = "unbound-control not found"
+		}
+	}
+
+	for _, l := range []ResolverLayer{nscd, dnsmasq, unbound} {
+		if l.Running || l.Binary != "" {
+			report.Layers = append(report.Layers, l)
+		}
+	}
+
+	if local := loopbackNameservers(report.Nameservers); len(local) > 0 && !report.hasRunningDaemon() {
+		report.Layers = append(report.Layers, ResolverLayer{
+			Name:    ResolverUnknownLocal,
+			Running: true,
+			Detail:  "resolv.conf points at " + strings.Join(local, ", ") + " but no known daemon was found",
+		})
+	}
+
+	return report
+}
+
+// hasRunningDaemon reports whether a layer other than the platform cache of
+// macOS or Windows runs, i.e. something that could answer on a loopback
+// nameserver address.
+func (r *ResolverReport) hasRunningDaemon() bool {
+	return slices.ContainsFunc(r.Layers, func(l ResolverLayer) bool {
+		return l.Running && l.Name != ResolverMDNSResponder && l.Name != ResolverDNSClient
+	})
+}
+
+// layer probes a single daemon.
+func (env resolverEnv) layer(d resolverDaemon, procs map[string][]int) ResolverLayer {
+	l := ResolverLayer{Name: d.name}
+	if d.process != "" {
+		l.PIDs = procs[d.process]
+		l.Running = len(l.PIDs) > 0
+	}
+	if d.stateDir != "" {
+		if fi, err := os.Stat(d.stateDir); err == nil && fi.IsDir() {
+			l.Running = true
+		}
+	}
+	for _, bin := range d.binaries {
+		if path, err := env.lookPath(bin); err == nil {
+			l.Binary = path
+			break
+		}
+	}
+	return l
+}
+
+// processes maps the command names of the processes under procDir to their
+// pids. It returns nil where there is no /proc.
+func (env resolverEnv) processes() map[string][]int {
+	entries, err := os.ReadDir(env.procDir)
+	if err != nil {
+		return nil
+	}
+
+	procs := make(map[string][]int)
+	for _, e := range entries {
+		pid, err := strconv.Atoi(e.Name())
+		if err != nil || !e.IsDir() {
+			continue
+		}
+		comm, err := os.ReadFile(filepath.Join(env.procDir, e.Name(), "comm"))
+		if err != nil {
+			continue // the process exited or belongs to another user
+		}
+		name := strings.TrimSpace(string(comm))
+		procs[name] = append(procs[name], pid)
+	}
+	for _, pids := range procs {
+		slices.Sort(pids)
+	}
+	return procs
+}
+
+// dnsmasqPIDFile returns the pid file of one of the dnsmasq processes: the
+// one given with --pid-file (or -x) on its command line, or a well-known path
+// holding one of pids.
+func (env resolverEnv) dnsmasqPIDFile(pids []int) string {
+	for _, pid := range pids {
+		cmdline, err := os.ReadFile(filepath.Join(env.procDir, strconv.Itoa(pid), "cmdline"))
+		if err != nil {
+			continue
+		}
+		if path := pidFileArg(strings.Split(strings.TrimRight(string(cmdline), "\x00"), "\x00")); path != "" {
+			return path
+		}
+	}
+
+	for _, path := range env.dnsmasqPIDs {
+		if pid, err := readPIDFile(path); err == nil && slices.Contains(pids, pid) {
+			return path
+		}
+	}
+	return ""
+}
+
+// pidFileArg returns the value of dnsmasq's --pid-file option in args.
+func pidFileArg(args []string) string {
+	for i, arg := range args {
+		switch {
+		case strings.HasPrefix(arg, "--pid-file="):
+			return strings.TrimPrefix(arg, "--pid-file=")
+		case strings.HasPrefix(arg, "-x") && len(arg) > 2:
+			return arg[2:]
+		case (arg == "-x" || arg == "--pid-file") && i+1 < len(args):
+			return args[i+1]
+		}
+	}
+	return ""
+}
+
+// nameservers returns the nameserver entries of resolv.conf.
+func (env resolverEnv) nameservers() []string {
+	f, err := os.Open(env.resolvConf)
+	if err != nil {
+		return nil
+	}
+	defer func() { _ = f.Close() }()
+
+	var servers []string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) >= 2 && fields[0] == "nameserver" {
+			servers = append(servers, fields[1])
+		}
+	}
+	return servers
+}
+
+// loopbackNameservers returns the servers that are loopback addresses, which
+// means a local daemon answers (and usually caches) queries.
+func loopbackNameservers(servers []string) []string {
+	var local []string
+	for _, s := range servers {
+		if a, err := netip.ParseAddr(s); err == nil && a.IsLoopback() {
+			local = append(local, s)
+		}
+	}
+	return local
+}
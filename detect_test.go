@@ -0,0 +1,236 @@
+package txeh
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// fakeSystem is a directory tree standing in for /proc, /run and /etc.
+type fakeSystem struct {
+	t    *testing.T
+	root string
+	bins map[string]bool
+}
+
+func newFakeSystem(t *testing.T) *fakeSystem {
+	t.Helper()
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "proc"), 0o750); err != nil {
+		t.Fatal(err)
+	}
+	return &fakeSystem{t: t, root: root, bins: map[string]bool{}}
+}
+
+// process adds /proc/<pid> with the given comm and NUL separated cmdline.
+func (fs *fakeSystem) process(pid int, comm string, args ...string) {
+	fs.t.Helper()
+	dir := filepath.Join(fs.root, "proc", strconv.Itoa(pid))
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		fs.t.Fatal(err)
+	}
+	cmdline := strings.Join(append([]string{comm}, args...), "\x00") + "\x00"
+	for name, content := range map[string]string{"comm": comm + "\n", "cmdline": cmdline} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			fs.t.Fatal(err)
+		}
+	}
+}
+
+// file writes a file relative to the fake root and returns its path.
+func (fs *fakeSystem) file(name, content string) string {
+	fs.t.Helper()
+	path := filepath.Join(fs.root, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		fs.t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		fs.t.Fatal(err)
+	}
+	return path
+}
+
+func (fs *fakeSystem) env() resolverEnv {
+	return resolverEnv{
+		lookPath: func(name string) (string, error) {
+			if fs.bins[name] {
+				return "/usr/bin/" + name, nil
+			}
+			return "", errors.New("not found")
+		},
+		procDir:    filepath.Join(fs.root, "proc"),
+		resolvConf: filepath.Join(fs.root, "etc/resolv.conf"),
+		system: resolverDaemon{
+			name:     ResolverSystemd,
+			process:  "systemd-resolve",
+			binaries: []string{"resolvectl", "systemd-resolve"},
+			stateDir: filepath.Join(fs.root, "run/systemd/resolve"),
+		},
+		dnsmasqPIDs: []string{filepath.Join(fs.root, "run/dnsmasq/dnsmasq.pid")},
+	}
+}
+
+func findLayer(r *ResolverReport, name string) *ResolverLayer {
+	for i := range r.Layers {
+		if r.Layers[i].Name == name {
+			return &r.Layers[i]
+		}
+	}
+	return nil
+}
+
+// Given a system running systemd-resolved, nscd, dnsmasq and unbound
+// When DetectResolvers inspects it
+// Then every layer is reported running and flushable with its flusher.
+func TestDetectResolvers_AllLayers(t *testing.T) {
+	t.Parallel()
+
+	fs := newFakeSystem(t)
+	fs.bins = map[string]bool{"systemd-resolve": true, "nscd": true, "unbound-control": true}
+	fs.file("run/systemd/resolve/resolv.conf", "")
+	fs.file("etc/resolv.conf", "# managed\nnameserver 127.0.0.53\noptions edns0\n")
+	fs.process(1, "systemd")
+	fs.process(210, "nscd")
+	fs.process(305, "dnsmasq", "--keep-in-foreground", "--pid-file=/run/nm-dnsmasq.pid")
+	fs.process(306, "unbound", "-d")
+
+	r := fs.env().detect()
+
+	if got := strings.Join(r.Nameservers, ","); got != "127.0.0.53" {
+		t.Errorf("Nameservers = %q", got)
+	}
+	want := []struct {
+		name    string
+		flusher Flusher
+	}{
+		{ResolverSystemd, SystemFlusher{}},
+		{ResolverNSCD, NSCDFlusher{}},
+		{ResolverDnsmasq, DnsmasqFlusher{PIDFile: "/run/nm-dnsmasq.pid"}},
+	}
+	if len(r.Layers) != 4 {
+		t.Fatalf("Layers = %v, want 4", r.Layers)
+	}
+	for i, w := range want {
+		l := r.Layers[i]
+		if l.Name != w.name || !l.Running || !l.Flushable || l.Flusher != w.flusher {
+			t.Errorf("Layers[%d] = %+v, want running and flushable %s with %#v", i, l, w.name, w.flusher)
+		}
+	}
+	if l := r.Layers[3]; l.Name != ResolverUnbound || !l.Flushable || len(l.PIDs) != 1 || l.PIDs[0] != 306 {
+		t.Errorf("Layers[3] = %+v, want flushable unbound with pid 306", l)
+	}
+	if l := findLayer(r, ResolverSystemd); l.Binary != "/usr/bin/systemd-resolve" || !strings.Contains(l.Detail, "127.0.0.53") {
+		t.Errorf("systemd layer = %+v", l)
+	}
+	if got := len(r.Flusher()); got != 4 {
+		t.Errorf("Flusher() has %d flushers, want 4", got)
+	}
+}
+
+// Given dnsmasq running without --pid-file
+// When DetectResolvers inspects it
+// Then a well-known pid file naming the process makes it flushable, and a
+// stale one does not.
+func TestDetectResolvers_DnsmasqPIDFile(t *testing.T) {
+	t.Parallel()
+
+	fs := newFakeSystem(t)
+	fs.process(42, "dnsmasq", "-k")
+	pidFile := fs.file("run/dnsmasq/dnsmasq.pid", "42\n")
+
+	l := findLayer(fs.env().detect(), ResolverDnsmasq)
+	if l == nil || !l.Flushable || l.Flusher != (DnsmasqFlusher{PIDFile: pidFile}) {
+		t.Errorf("dnsmasq layer = %+v, want flushable through %s", l, pidFile)
+	}
+
+	fs.file("run/dnsmasq/dnsmasq.pid", "99\n")
+	l = findLayer(fs.env().detect(), ResolverDnsmasq)
+	if l == nil || !l.Running || l.Flushable || l.Detail != "no pid file found" {
+		t.Errorf("dnsmasq layer = %+v, want running but not flushable", l)
+	}
+}
+
+// Given installed but stopped daemons
+// When DetectResolvers inspects the system
+// Then they are reported as not running and nothing is flushed.
+func TestDetectResolvers_InstalledNotRunning(t *testing.T) {
+	t.Parallel()
+
+	fs := newFakeSystem(t)
+	fs.bins = map[string]bool{"resolvectl": true, "nscd": true}
+	fs.file("etc/resolv.conf", "nameserver 192.0.2.1\nnameserver 2001:db8::1\n")
+
+	r := fs.env().detect()
+	if len(r.Layers) != 2 {
+		t.Fatalf("Layers = %v, want systemd-resolved and nscd", r.Layers)
+	}
+	for _, l := range r.Layers {
+		if l.Running || l.Flushable {
+			t.Errorf("layer %v, want not running and not flushable", l)
+		}
+	}
+	if len(r.Flusher()) != 0 {
+		t.Errorf("Flusher() = %v, want empty chain", r.Flusher())
+	}
+	if len(r.Nameservers) != 2 {
+		t.Errorf("Nameservers = %v", r.Nameservers)
+	}
+}
+
+// Given resolv.conf pointing at loopback and no known daemon
+// When DetectResolvers inspects the system
+// Then an unknown local resolver is reported that can't be flushed.
+func TestDetectResolvers_UnknownLocalResolver(t *testing.T) {
+	t.Parallel()
+
+	fs := newFakeSystem(t)
+	fs.file("etc/resolv.conf", "nameserver 127.0.0.1\n")
+
+	r := fs.env().detect()
+	if len(r.Layers) != 1 || r.Layers[0].Name != ResolverUnknownLocal || r.Layers[0].Flushable {
+		t.Fatalf("Layers = %v, want one unflushable local resolver", r.Layers)
+	}
+	if want := "local resolver: running, not flushable (resolv.conf points at 127.0.0.1 but no known daemon was found)"; r.Layers[0].String() != want {
+		t.Errorf("String() = %q, want %q", r.Layers[0].String(), want)
+	}
+}
+
+// Given a system without /proc or resolv.conf
+// When DetectResolvers inspects it
+// Then it reports nothing rather than failing.
+func TestDetectResolvers_NothingFound(t *testing.T) {
+	t.Parallel()
+
+	fs := newFakeSystem(t)
+	env := fs.env()
+	env.procDir = filepath.Join(fs.root, "missing")
+
+	r := env.detect()
+	if len(r.Layers) != 0 || len(r.Nameservers) != 0 {
+		t.Errorf("report = %+v, want empty", r)
+	}
+}
+
+func TestPidFileArg(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"dnsmasq", "--pid-file=/a.pid"}, "/a.pid"},
+		{[]string{"dnsmasq", "--pid-file", "/b.pid"}, "/b.pid"},
+		{[]string{"dnsmasq", "-x", "/c.pid"}, "/c.pid"},
+		{[]string{"dnsmasq", "-x/d.pid"}, "/d.pid"},
+		{[]string{"dnsmasq", "-k"}, ""},
+		{[]string{"dnsmasq", "-x"}, ""},
+	}
+	for _, tt := range tests {
+		if got := pidFileArg(tt.args); got != tt.want {
+			t.Errorf("pidFileArg(%q) = %q, want %q", tt.args, got, tt.want)
+		}
+	}
+}
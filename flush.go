@@ -1,9 +1,13 @@
 package txeh
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os/exec"
+	"runtime"
 	"strings"
+	"time"
 )
 
 // execCommandFunc is a variable that wraps exec.Command for testability.
@@ -11,26 +15,54 @@ var execCommandFunc = exec.Command
 
 // ExecCommandFunc returns the current exec command function.
 // This is intended for test code that needs to save and restore the original.
+//
+// Deprecated: Set HostsConfig.Flusher instead of mocking command execution.
 func ExecCommandFunc() func(string, ...string) *exec.Cmd {
 	return execCommandFunc
 }
 
-// SetExecCommandFunc replaces the exec command function.
-// This is intended for test code that needs to mock command execution.
+// SetExecCommandFunc replaces the exec command function used by the built-in
+// flushers. This is intended for test code that needs to mock command execution.
+//
+// Deprecated: Set HostsConfig.Flusher, for example to a FlusherFunc, instead
+// of replacing this process-wide hook.
 func SetExecCommandFunc(fn func(string, ...string) *exec.Cmd) {
 	execCommandFunc = fn
 }
 
+// DefaultFlushBackoff is the wait before the first retry of
+// FlushDNSCacheContext when FlushOptions.Backoff is zero.
+const DefaultFlushBackoff = 250 * time.Millisecond
+
+// DefaultFlushTimeout limits each attempt of SystemFlusher, NSCDFlusher and
+// UnboundFlusher when FlushOptions.Timeout is zero, so a hung flush command
+// can't block a save forever.
+const DefaultFlushTimeout = 10 * time.Second
+
 // FlushError represents a DNS cache flush failure with platform context.
 type FlushError struct {
 	Platform string
 	Command  string
 	Err      error
+	// Attempts lists every failed attempt of FlushDNSCacheContext, oldest
+	// first. Command and Err describe the last one.
+	Attempts []FlushAttempt
+}
+
+// FlushAttempt is a failed attempt to flush the DNS cache.
+type FlushAttempt struct {
+	Command  string
+	Err      error
+	Duration time.Duration
 }
 
 // Error returns a human-readable error message.
 func (e *FlushError) Error() string {
-	return fmt.Sprintf("flush DNS cache on %s (%s): %s", e.Platform, e.Command, e.Err)
+	msg := fmt.Sprintf("flush DNS cache on %s (%s): %s", e.Platform, e.Command, e.Err)
+	if len(e.Attempts) > 1 {
+		msg += fmt.Sprintf(" (%d attempts)", len(e.Attempts))
+	}
+	return msg
 }
 
 // Unwrap returns the underlying error.
@@ -50,7 +82,102 @@ func (e *FlushError) Unwrap() error {
 // Returns a *FlushError on failure, or nil on unsupported platforms
 // where no resolver is detected.
 func FlushDNSCache() error {
-	return flushDNSCachePlatform()
+	return flushDNSCachePlatform(context.Background())
+}
+
+// FlushOptions control the attempts of FlushDNSCacheContext.
+type FlushOptions struct {
+	// Timeout limits each attempt; a command still running when it expires
+	// is killed. Zero means no limit beyond the context's for
+	// FlushDNSCacheContext and DefaultFlushTimeout for the flushers;
+	// negative means no limit.
+	Timeout time.Duration
+	// Retries is the number of attempts made after a failed first one.
+	Retries int
+	// Backoff is the wait before the first retry, doubled before each
+	// further one. Zero means DefaultFlushBackoff.
+	Backoff time.Duration
+}
+
+// FlushDNSCacheContext is FlushDNSCache with a deadline and retries. The
+// flush commands are killed when ctx is done or an attempt exceeds
+// opts.Timeout. Failed attempts are retried up to opts.Retries times, except
+// when there is no command to run (e.g. ErrNoResolver on Linux). The
+// returned *FlushError lists every attempt in Attempts.
+func FlushDNSCacheContext(ctx context.Context, opts FlushOptions) error {
+	return flushWithRetries(ctx, opts, flushDNSCachePlatform)
+}
+
+// flushWithRetries runs flush under the timeout and retries of opts, like
+// FlushDNSCacheContext does for the platform flush.
+func flushWithRetries(ctx context.Context, opts FlushOptions, flush func(context.Context) error) error {
+	backoff := opts.Backoff
+	if backoff == 0 {
+		backoff = DefaultFlushBackoff
+	}
+
+	var attempts []FlushAttempt
+	for attempt := 0; ; attempt++ {
+		start := time.Now()
+		err := flushAttempt(ctx, opts.Timeout, flush)
+		if err == nil {
+			return nil
+		}
+
+		var fe *FlushError
+		if !errors.As(err, &fe) {
+			fe = &FlushError{Platform: runtime.GOOS, Err: err}
+		}
+		attempts = append(attempts, FlushAttempt{Command: fe.Command, Err: fe.Err, Duration: time.Since(start)})
+		fe.Attempts = attempts
+
+		// Without a command there is nothing to retry.
+		if fe.Command == "" || attempt >= opts.Retries || ctx.Err() != nil {
+			return fe
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return &FlushError{Platform: fe.Platform, Command: fe.Command, Err: ctx.Err(), Attempts: attempts}
+		case <-timer.C:
+		}
+		backoff *= 2
+	}
+}
+
+// flushAttempt runs flush once, limited to timeout if set.
+func flushAttempt(ctx context.Context, timeout time.Duration, flush func(context.Context) error) error {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	return flush(ctx)
+}
+
+// runContext runs cmd and kills it when ctx is done. Unlike
+// exec.CommandContext it works on commands built by execCommandFunc.
+func runContext(ctx context.Context, cmd *exec.Cmd) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		_ = cmd.Process.Kill()
+		<-done
+		return ctx.Err()
+	}
 }
 
 // joinArgs joins command arguments for error display.
@@ -2,7 +2,10 @@
 
 package txeh
 
-import "runtime"
+import (
+	"context"
+	"runtime"
+)
 
 // flushDNSCachePlatform flushes the DNS cache on macOS.
 //
@@ -21,9 +24,9 @@ import "runtime"
 // Both binaries ship with macOS. No external dependencies.
 //
 // The killall step is non-fatal since mDNSResponder may not be running.
-func flushDNSCachePlatform() error {
+func flushDNSCachePlatform(ctx context.Context) error {
 	cmd := execCommandFunc("dscacheutil", "-flushcache") // #nosec G204 -- hardcoded command, not user input
-	if err := cmd.Run(); err != nil {
+	if err := runContext(ctx, cmd); err != nil {
 		return &FlushError{
 			Platform: runtime.GOOS,
 			Command:  joinArgs("dscacheutil", []string{"-flushcache"}),
@@ -34,7 +37,10 @@ func flushDNSCachePlatform() error {
 	// Attempt to restart mDNSResponder. This may fail if the process
 	// is not running, which is not an error condition.
 	cmd = execCommandFunc("killall", "-HUP", "mDNSResponder") // #nosec G204 -- hardcoded command, not user input
-	_ = cmd.Run()
+	_ = runContext(ctx, cmd)
 
 	return nil
 }
+
+// systemResolver is the cache flushDNSCachePlatform flushes.
+var systemResolver = resolverDaemon{name: ResolverMDNSResponder, binaries: []string{"dscacheutil"}}
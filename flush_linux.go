@@ -3,6 +3,7 @@
 package txeh
 
 import (
+	"context"
 	"errors"
 	"os/exec"
 	"runtime"
@@ -26,14 +27,15 @@ var ErrNoResolver = errors.New(
 // Tries resolvectl first, then falls back to systemd-resolve.
 // Returns ErrNoResolver (wrapped in FlushError) if neither is available.
 //
-// Other resolvers (dnsmasq, unbound, nscd) are intentionally not supported here
-// because flushing them reliably depends on per-site configuration (socket paths,
-// service names, etc.) that we can't safely assume.
-func flushDNSCachePlatform() error {
+// Other resolvers (dnsmasq, unbound, nscd) are not flushed here because doing so
+// reliably depends on per-site configuration (pid files, control sockets, etc.)
+// that we can't safely assume. Opt in with DnsmasqFlusher, UnboundFlusher and
+// NSCDFlusher on HostsConfig.Flusher.
+func flushDNSCachePlatform(ctx context.Context) error {
 	// Try resolvectl first (systemd 239+).
 	if _, err := exec.LookPath("resolvectl"); err == nil {
 		cmd := execCommandFunc("resolvectl", "flush-caches") // #nosec G204 -- hardcoded command, not user input
-		if err := cmd.Run(); err != nil {
+		if err := runContext(ctx, cmd); err != nil {
 			return &FlushError{
 				Platform: runtime.GOOS,
 				Command:  joinArgs("resolvectl", []string{"flush-caches"}),
@@ -46,7 +48,7 @@ func flushDNSCachePlatform() error {
 	// Fallback to systemd-resolve (older systemd).
 	if _, err := exec.LookPath("systemd-resolve"); err == nil {
 		cmd := execCommandFunc("systemd-resolve", "--flush-caches") // #nosec G204 -- hardcoded command, not user input
-		if err := cmd.Run(); err != nil {
+		if err := runContext(ctx, cmd); err != nil {
 			return &FlushError{
 				Platform: runtime.GOOS,
 				Command:  joinArgs("systemd-resolve", []string{"--flush-caches"}),
@@ -62,3 +64,11 @@ func flushDNSCachePlatform() error {
 		Err:      ErrNoResolver,
 	}
 }
+
+// systemResolver is the cache flushDNSCachePlatform flushes.
+var systemResolver = resolverDaemon{
+	name:     ResolverSystemd,
+	process:  "systemd-resolve", // "systemd-resolved" truncated to 15 bytes
+	binaries: []string{"resolvectl", "systemd-resolve"},
+	stateDir: "/run/systemd/resolve",
+}
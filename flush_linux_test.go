@@ -3,9 +3,15 @@
 package txeh
 
 import (
+	"context"
 	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 // --- Platform flush command verification (linux) ---
@@ -85,3 +91,125 @@ func TestErrNoResolver_Message(t *testing.T) {
 		t.Errorf("ErrNoResolver should explain no flush needed, got: %q", msg)
 	}
 }
+
+// --- FlushDNSCacheContext (linux) ---
+
+// fakeResolvectl puts an executable named resolvectl first on PATH so the
+// flush takes the resolvectl branch; what actually runs is execCommandFunc.
+func fakeResolvectl(t *testing.T) {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "resolvectl"), []byte("#!/bin/sh\n"), 0o700); err != nil { // #nosec G306 -- must be executable
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+// Given a flush command that always fails
+// When FlushDNSCacheContext is called with two retries
+// Then the command runs three times and every attempt is reported.
+func TestFlushDNSCacheContext_RetriesAndReportsAttempts(t *testing.T) {
+	fakeResolvectl(t)
+	origFunc := execCommandFunc
+	defer func() { execCommandFunc = origFunc }()
+	fn, calls, _ := mockExec("false")
+	execCommandFunc = fn
+
+	err := FlushDNSCacheContext(context.Background(), FlushOptions{Retries: 2, Backoff: time.Millisecond})
+
+	var fe *FlushError
+	if !errors.As(err, &fe) {
+		t.Fatalf("expected *FlushError, got %T: %v", err, err)
+	}
+	if len(*calls) != 3 || len(fe.Attempts) != 3 {
+		t.Fatalf("ran %d commands with %d attempts reported, want 3 and 3", len(*calls), len(fe.Attempts))
+	}
+	for i, a := range fe.Attempts {
+		if a.Command != "resolvectl flush-caches" || a.Err == nil {
+			t.Errorf("Attempts[%d] = %+v", i, a)
+		}
+	}
+	if !strings.HasSuffix(fe.Error(), "(3 attempts)") {
+		t.Errorf("Error() = %q, want attempt count", fe.Error())
+	}
+}
+
+// Given a flush command that fails once and then succeeds
+// When FlushDNSCacheContext is called with retries
+// Then it returns nil after the second attempt.
+func TestFlushDNSCacheContext_SucceedsOnRetry(t *testing.T) {
+	fakeResolvectl(t)
+	origFunc := execCommandFunc
+	defer func() { execCommandFunc = origFunc }()
+	var n atomic.Int32
+	execCommandFunc = func(string, ...string) *exec.Cmd {
+		if n.Add(1) == 1 {
+			return exec.CommandContext(context.Background(), "false")
+		}
+		return exec.CommandContext(context.Background(), "true")
+	}
+
+	if err := FlushDNSCacheContext(context.Background(), FlushOptions{Retries: 3, Backoff: time.Millisecond}); err != nil {
+		t.Fatalf("FlushDNSCacheContext() error = %v", err)
+	}
+	if n.Load() != 2 {
+		t.Errorf("ran %d commands, want 2", n.Load())
+	}
+}
+
+// Given a flush command that hangs
+// When FlushDNSCacheContext is called with a timeout
+// Then the command is killed and the error wraps context.DeadlineExceeded.
+func TestFlushDNSCacheContext_TimeoutKillsCommand(t *testing.T) {
+	fakeResolvectl(t)
+	origFunc := execCommandFunc
+	defer func() { execCommandFunc = origFunc }()
+	execCommandFunc = func(string, ...string) *exec.Cmd {
+		return exec.CommandContext(context.Background(), "sleep", "30")
+	}
+
+	start := time.Now()
+	err := FlushDNSCacheContext(context.Background(), FlushOptions{Timeout: 50 * time.Millisecond})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("error = %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("returned after %s, want the command killed", elapsed)
+	}
+}
+
+// Given a failed attempt waiting to be retried
+// When the context is canceled during the backoff
+// Then FlushDNSCacheContext returns context.Canceled without retrying.
+func TestFlushDNSCacheContext_CanceledDuringBackoff(t *testing.T) {
+	fakeResolvectl(t)
+	origFunc := execCommandFunc
+	defer func() { execCommandFunc = origFunc }()
+	fn, calls, _ := mockExec("false")
+	execCommandFunc = fn
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	err := FlushDNSCacheContext(ctx, FlushOptions{Retries: 1, Backoff: time.Hour})
+	var fe *FlushError
+	if !errors.Is(err, context.Canceled) || !errors.As(err, &fe) || len(fe.Attempts) != 1 {
+		t.Fatalf("error = %v, want *FlushError with one attempt wrapping context.Canceled", err)
+	}
+	if len(*calls) != 1 {
+		t.Errorf("ran %d commands, want 1", len(*calls))
+	}
+}
+
+// Given no systemd-resolved on PATH
+// When FlushDNSCacheContext is called with retries
+// Then ErrNoResolver is returned after a single attempt.
+func TestFlushDNSCacheContext_NoResolverNotRetried(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+
+	err := FlushDNSCacheContext(context.Background(), FlushOptions{Retries: 3, Backoff: time.Millisecond})
+	var fe *FlushError
+	if !errors.Is(err, ErrNoResolver) || !errors.As(err, &fe) || len(fe.Attempts) != 1 {
+		t.Fatalf("error = %v, want ErrNoResolver after one attempt", err)
+	}
+}
@@ -3,15 +3,19 @@
 package txeh
 
 import (
+	"context"
 	"errors"
 	"runtime"
 )
 
 // flushDNSCachePlatform returns an error on unsupported platforms.
-func flushDNSCachePlatform() error {
+func flushDNSCachePlatform(context.Context) error {
 	return &FlushError{
 		Platform: runtime.GOOS,
 		Command:  "",
 		Err:      errors.New("unsupported platform"),
 	}
 }
+
+// systemResolver is empty: there is no platform cache to report.
+var systemResolver = resolverDaemon{}
@@ -2,7 +2,10 @@
 
 package txeh
 
-import "runtime"
+import (
+	"context"
+	"runtime"
+)
 
 // flushDNSCachePlatform flushes the DNS cache on Windows.
 //
@@ -10,9 +13,9 @@ import "runtime"
 // Source: https://learn.microsoft.com/en-us/windows-server/administration/windows-commands/ipconfig
 // Present in all supported Windows versions (XP through current).
 // Ships with Windows. No external dependencies.
-func flushDNSCachePlatform() error {
+func flushDNSCachePlatform(ctx context.Context) error {
 	cmd := execCommandFunc("ipconfig", "/flushdns") // #nosec G204 -- hardcoded command, not user input
-	if err := cmd.Run(); err != nil {
+	if err := runContext(ctx, cmd); err != nil {
 		return &FlushError{
 			Platform: runtime.GOOS,
 			Command:  joinArgs("ipconfig", []string{"/flushdns"}),
@@ -21,3 +24,6 @@ func flushDNSCachePlatform() error {
 	}
 	return nil
 }
+
+// systemResolver is the cache flushDNSCachePlatform flushes.
+var systemResolver = resolverDaemon{name: ResolverDNSClient, binaries: []string{"ipconfig"}}
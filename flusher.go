@@ -0,0 +1,229 @@
+package txeh
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// Flusher flushes a DNS cache so hosts file changes take effect. Set
+// HostsConfig.Flusher to choose what AutoFlush runs, or to stub flushing out
+// in tests.
+type Flusher interface {
+	Flush() error
+}
+
+// ContextFlusher is a Flusher whose commands can be canceled. ChainFlusher
+// passes its context on to the flushers that implement it.
+type ContextFlusher interface {
+	Flusher
+	FlushContext(ctx context.Context) error
+}
+
+// FlusherFunc adapts a function to the Flusher interface.
+type FlusherFunc func() error
+
+// Flush calls f.
+func (f FlusherFunc) Flush() error {
+	return f()
+}
+
+// SystemFlusher flushes the operating system's DNS cache (see
+// FlushDNSCacheContext). AutoFlush uses the zero value, which makes a single
+// attempt limited to DefaultFlushTimeout, when HostsConfig.Flusher is nil. A
+// negative Timeout waits for the command however long it takes.
+type SystemFlusher struct {
+	FlushOptions
+}
+
+// Flush runs the platform flush commands.
+func (f SystemFlusher) Flush() error {
+	return f.FlushContext(context.Background())
+}
+
+// FlushContext runs the platform flush commands until ctx is done.
+func (f SystemFlusher) FlushContext(ctx context.Context) error {
+	return FlushDNSCacheContext(ctx, f.withDefaults())
+}
+
+// withDefaults returns o with DefaultFlushTimeout if Timeout is zero.
+func (o FlushOptions) withDefaults() FlushOptions {
+	if o.Timeout == 0 {
+		o.Timeout = DefaultFlushTimeout
+	}
+	return o
+}
+
+// NSCDFlusher invalidates the hosts cache of the name service cache daemon
+// by running "nscd -i hosts". FlushOptions limit and retry the command as
+// for SystemFlusher.
+type NSCDFlusher struct {
+	FlushOptions
+}
+
+// Flush runs nscd -i hosts.
+func (f NSCDFlusher) Flush() error {
+	return f.FlushContext(context.Background())
+}
+
+// FlushContext runs nscd -i hosts, killing it when ctx is done.
+func (f NSCDFlusher) FlushContext(ctx context.Context) error {
+	return flushWithRetries(ctx, f.withDefaults(), func(ctx context.Context) error {
+		return runFlushCommand(ctx, "nscd", "-i", "hosts")
+	})
+}
+
+// DnsmasqFlusher sends SIGHUP to dnsmasq, which clears its cache and re-reads
+// /etc/hosts. The process is found through its pid file, so it only works
+// where dnsmasq runs with --pid-file.
+type DnsmasqFlusher struct {
+	// PIDFile is the pid file dnsmasq writes, e.g. /run/dnsmasq/dnsmasq.pid.
+	PIDFile string
+}
+
+// Flush signals the dnsmasq process.
+func (f DnsmasqFlusher) Flush() error {
+	return f.FlushContext(context.Background())
+}
+
+// FlushContext signals the dnsmasq process unless ctx is already done.
+func (f DnsmasqFlusher) FlushContext(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return &FlushError{Platform: runtime.GOOS, Command: "kill -HUP $(cat " + f.PIDFile + ")", Err: err}
+	}
+
+	pid, err := readPIDFile(f.PIDFile)
+	if err != nil {
+		return &FlushError{Platform: runtime.GOOS, Command: "kill -HUP $(cat " + f.PIDFile + ")", Err: err}
+	}
+
+	command := joinArgs("kill", []string{"-HUP", strconv.Itoa(pid)})
+	if err := sendSIGHUP(pid); err != nil {
+		return &FlushError{Platform: runtime.GOOS, Command: command, Err: err}
+	}
+
+	return nil
+}
+
+// readPIDFile returns the process id stored in path.
+func readPIDFile(path string) (int, error) {
+	if path == "" {
+		return 0, errors.New("no pid file configured")
+	}
+
+	data, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return 0, err
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || pid <= 0 {
+		return 0, fmt.Errorf("pid file %s: invalid pid %q", path, strings.TrimSpace(string(data)))
+	}
+
+	return pid, nil
+}
+
+// UnboundFlusher flushes unbound through unbound-control. By default it runs
+// "unbound-control flush_zone" for each of Zones; with Reload set it runs
+// "unbound-control reload" instead, which drops the whole cache and re-reads
+// the configuration. FlushOptions limit and retry the commands as for
+// SystemFlusher.
+type UnboundFlusher struct {
+	FlushOptions
+	// Zones are the zones to flush. Empty means ".", every cached name.
+	Zones []string
+	// Reload restarts unbound's resolver instead of flushing zones.
+	Reload bool
+}
+
+// Flush runs unbound-control.
+func (f UnboundFlusher) Flush() error {
+	return f.FlushContext(context.Background())
+}
+
+// FlushContext runs unbound-control, killing it when ctx is done.
+func (f UnboundFlusher) FlushContext(ctx context.Context) error {
+	return flushWithRetries(ctx, f.withDefaults(), func(ctx context.Context) error {
+		if f.Reload {
+			return runFlushCommand(ctx, "unbound-control", "reload")
+		}
+
+		zones := f.Zones
+		if len(zones) == 0 {
+			zones = []string{"."}
+		}
+		for _, zone := range zones {
+			if err := runFlushCommand(ctx, "unbound-control", "flush_zone", zone); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// ChainFlusher runs several flushers in order. Every flusher runs even when
+// an earlier one fails; the failures are returned together (see errors.Join).
+type ChainFlusher []Flusher
+
+// Flush runs each flusher of the chain.
+func (c ChainFlusher) Flush() error {
+	return c.FlushContext(context.Background())
+}
+
+// FlushContext runs each flusher of the chain, passing ctx to those that
+// implement ContextFlusher.
+func (c ChainFlusher) FlushContext(ctx context.Context) error {
+	var errs []error
+	for _, f := range c {
+		var err error
+		if cf, ok := f.(ContextFlusher); ok {
+			err = cf.FlushContext(ctx)
+		} else {
+			err = f.Flush()
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// runFlushCommand runs a flush command until ctx is done and returns a
+// *FlushError when it fails.
+func runFlushCommand(ctx context.Context, name string, args ...string) error {
+	cmd := execCommandFunc(name, args...) // #nosec G204 -- fixed command names, arguments from trusted configuration
+	if err := runContext(ctx, cmd); err != nil {
+		return &FlushError{Platform: runtime.GOOS, Command: joinArgs(name, args), Err: err}
+	}
+	return nil
+}
+
+// autoFlushLocked runs the configured flusher when AutoFlush is set. Errors
+// are returned as *FlushError. Must be called with the lock held.
+func (h *Hosts) autoFlushLocked() error {
+	if !h.AutoFlush {
+		return nil
+	}
+	if h.coalesce.holdFlush {
+		h.coalesce.unflushed = true
+		return nil
+	}
+
+	var f Flusher = SystemFlusher{}
+	if h.Flusher != nil {
+		f = h.Flusher
+	}
+
+	err := f.Flush()
+	var fe *FlushError
+	if err != nil && !errors.As(err, &fe) {
+		return &FlushError{Platform: runtime.GOOS, Err: err}
+	}
+	return err
+}
@@ -0,0 +1,13 @@
+//go:build !unix
+
+package txeh
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// sendSIGHUP fails: there is no SIGHUP on this platform.
+func sendSIGHUP(int) error {
+	return fmt.Errorf("sending SIGHUP is not supported on %s", runtime.GOOS)
+}
@@ -0,0 +1,202 @@
+package txeh
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Given AutoFlush with a custom Flusher
+// When Save is called
+// Then the flusher runs instead of the platform commands.
+func TestAutoFlush_UsesConfiguredFlusher(t *testing.T) {
+	origFunc := execCommandFunc
+	defer func() { execCommandFunc = origFunc }()
+	fn, calls, _ := mockExec("true")
+	execCommandFunc = fn
+
+	flushed := 0
+	hosts, err := NewHosts(&HostsConfig{
+		Store:     NewMemoryStore(testHostsLocalhost),
+		AutoFlush: true,
+		Flusher:   FlusherFunc(func() error { flushed++; return nil }),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := hosts.Save(); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if flushed != 1 {
+		t.Errorf("flusher ran %d times, want 1", flushed)
+	}
+	if len(*calls) != 0 {
+		t.Errorf("platform commands ran: %v", *calls)
+	}
+}
+
+// Given a custom Flusher that fails with a plain error
+// When Save is called
+// Then the error is returned as a *FlushError wrapping it.
+func TestAutoFlush_PlainErrorWrappedInFlushError(t *testing.T) {
+	t.Parallel()
+
+	boom := errors.New("boom")
+	hosts, err := NewHosts(&HostsConfig{
+		Store:     NewMemoryStore(testHostsLocalhost),
+		AutoFlush: true,
+		Flusher:   FlusherFunc(func() error { return boom }),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = hosts.Save()
+	var fe *FlushError
+	if !errors.As(err, &fe) || !errors.Is(err, boom) {
+		t.Errorf("Save() error = %v, want *FlushError wrapping %v", err, boom)
+	}
+}
+
+// Given the nscd and unbound flushers
+// When they flush
+// Then they run the documented commands.
+func TestCommandFlushers_RunCommands(t *testing.T) {
+	origFunc := execCommandFunc
+	defer func() { execCommandFunc = origFunc }()
+
+	tests := []struct {
+		name    string
+		flusher Flusher
+		want    []string
+	}{
+		{"nscd", NSCDFlusher{}, []string{"nscd -i hosts"}},
+		{"unbound default", UnboundFlusher{}, []string{"unbound-control flush_zone ."}},
+		{"unbound zones", UnboundFlusher{Zones: []string{"local", "test"}}, []string{
+			"unbound-control flush_zone local", "unbound-control flush_zone test",
+		}},
+		{"unbound reload", UnboundFlusher{Zones: []string{"local"}, Reload: true}, []string{"unbound-control reload"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fn, calls, _ := mockExec("true")
+			execCommandFunc = fn
+
+			if err := tt.flusher.Flush(); err != nil {
+				t.Fatalf("Flush() error = %v", err)
+			}
+			var got []string
+			for _, c := range *calls {
+				got = append(got, joinArgs(c.Name, c.Args))
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("commands = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// Given a failing command
+// When the nscd flusher runs
+// Then a *FlushError names the command.
+func TestNSCDFlusher_Failure(t *testing.T) {
+	origFunc := execCommandFunc
+	defer func() { execCommandFunc = origFunc }()
+	fn, _, _ := mockExec("false")
+	execCommandFunc = fn
+
+	err := NSCDFlusher{}.Flush()
+	var fe *FlushError
+	if !errors.As(err, &fe) || fe.Command != "nscd -i hosts" {
+		t.Errorf("Flush() error = %v, want *FlushError for nscd -i hosts", err)
+	}
+}
+
+// Given a failing command and Retries
+// When the unbound flusher runs
+// Then the command is retried and every attempt is reported.
+func TestUnboundFlusher_Retries(t *testing.T) {
+	origFunc := execCommandFunc
+	defer func() { execCommandFunc = origFunc }()
+	fn, calls, _ := mockExec("false")
+	execCommandFunc = fn
+
+	err := UnboundFlusher{FlushOptions: FlushOptions{Retries: 2, Backoff: time.Millisecond}}.Flush()
+	var fe *FlushError
+	if !errors.As(err, &fe) || len(fe.Attempts) != 3 || fe.Command != "unbound-control flush_zone ." {
+		t.Fatalf("Flush() error = %v, want *FlushError with 3 attempts", err)
+	}
+	if len(*calls) != 3 {
+		t.Errorf("ran %d commands, want 3", len(*calls))
+	}
+}
+
+func TestFlushOptions_WithDefaults(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		timeout time.Duration
+		want    time.Duration
+	}{
+		{0, DefaultFlushTimeout},
+		{time.Second, time.Second},
+		{-1, -1},
+	}
+	for _, tt := range tests {
+		if got := (FlushOptions{Timeout: tt.timeout}).withDefaults().Timeout; got != tt.want {
+			t.Errorf("withDefaults() with Timeout %s = %s, want %s", tt.timeout, got, tt.want)
+		}
+	}
+}
+
+// Given a missing, empty or malformed pid file
+// When the dnsmasq flusher runs
+// Then it fails with a *FlushError.
+func TestDnsmasqFlusher_BadPIDFile(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	garbage := filepath.Join(dir, "garbage.pid")
+	if err := os.WriteFile(garbage, []byte("not-a-pid\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{"", filepath.Join(dir, "missing.pid"), garbage} {
+		err := DnsmasqFlusher{PIDFile: path}.Flush()
+		var fe *FlushError
+		if !errors.As(err, &fe) {
+			t.Errorf("Flush(%q) error = %v, want *FlushError", path, err)
+		}
+	}
+}
+
+// Given a chain whose first flusher fails
+// When it flushes
+// Then every flusher runs and all failures are returned.
+func TestChainFlusher_RunsAllAndJoinsErrors(t *testing.T) {
+	t.Parallel()
+
+	errA, errB := errors.New("a failed"), errors.New("b failed")
+	var ran []string
+	chain := ChainFlusher{
+		FlusherFunc(func() error { ran = append(ran, "a"); return errA }),
+		FlusherFunc(func() error { ran = append(ran, "ok"); return nil }),
+		FlusherFunc(func() error { ran = append(ran, "b"); return errB }),
+	}
+
+	err := chain.Flush()
+	if !slices.Equal(ran, []string{"a", "ok", "b"}) {
+		t.Errorf("ran %v, want [a ok b]", ran)
+	}
+	if !errors.Is(err, errA) || !errors.Is(err, errB) || !strings.Contains(err.Error(), "b failed") {
+		t.Errorf("Flush() error = %v, want both failures", err)
+	}
+	if err := (ChainFlusher{}).Flush(); err != nil {
+		t.Errorf("empty chain Flush() error = %v", err)
+	}
+}
@@ -0,0 +1,17 @@
+//go:build unix
+
+package txeh
+
+import (
+	"os"
+	"syscall"
+)
+
+// sendSIGHUP sends SIGHUP to the process pid.
+func sendSIGHUP(pid int) error {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+	return p.Signal(syscall.SIGHUP)
+}
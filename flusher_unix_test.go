@@ -0,0 +1,69 @@
+//go:build unix
+
+package txeh
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// Given a pid file naming this test process
+// When the dnsmasq flusher runs
+// Then the process receives SIGHUP.
+func TestDnsmasqFlusher_SendsSIGHUP(t *testing.T) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	pidFile := filepath.Join(t.TempDir(), "dnsmasq.pid")
+	if err := os.WriteFile(pidFile, []byte(strconv.Itoa(os.Getpid())+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := (DnsmasqFlusher{PIDFile: pidFile}).Flush(); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+
+	select {
+	case <-hup:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no SIGHUP received")
+	}
+}
+
+// Given nscd and unbound commands that hang
+// When their flushers run with a timeout, alone or in a chain
+// Then the commands are killed and the errors wrap context.DeadlineExceeded.
+func TestCommandFlushers_TimeoutKillsCommand(t *testing.T) {
+	origFunc := execCommandFunc
+	defer func() { execCommandFunc = origFunc }()
+	execCommandFunc = func(string, ...string) *exec.Cmd {
+		return exec.CommandContext(context.Background(), "sleep", "30")
+	}
+
+	opts := FlushOptions{Timeout: 50 * time.Millisecond}
+	start := time.Now()
+	for _, f := range []Flusher{NSCDFlusher{opts}, UnboundFlusher{FlushOptions: opts}} {
+		var fe *FlushError
+		if err := f.Flush(); !errors.Is(err, context.DeadlineExceeded) || !errors.As(err, &fe) {
+			t.Errorf("%T.Flush() error = %v, want *FlushError wrapping context.DeadlineExceeded", f, err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := (ChainFlusher{NSCDFlusher{}, UnboundFlusher{}}).FlushContext(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("ChainFlusher.FlushContext() error = %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("returned after %s, want the commands killed", elapsed)
+	}
+}
@@ -4,10 +4,12 @@ go 1.24.7
 
 require (
 	github.com/spf13/cobra v1.10.2
+	golang.org/x/net v0.50.0
 	pgregory.net/rapid v1.3.0
 )
 
 require (
 	github.com/inconshreveable/mousetrap v1.1.0 // indirect
 	github.com/spf13/pflag v1.0.9 // indirect
+	golang.org/x/text v0.34.0 // indirect
 )
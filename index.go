@@ -0,0 +1,210 @@
+package txeh
+
+import (
+	"net/netip"
+	"slices"
+)
+
+// lineIndex maps hostnames and addresses to the positions of the address
+// lines holding them, so lookups and adds do not scan the whole file. It is
+// built on first use. Adding a hostname to a line, appending a line and
+// removing a hostname from a line that stays update it in place; anything
+// that inserts, removes or reorders lines drops it (see invalidateIndexLocked).
+type lineIndex struct {
+	// byHost and byAddr hold ascending line positions.
+	byHost map[string][]int
+	byAddr map[netip.Addr][]int
+	// inBlock holds the positions of address lines inside managed blocks.
+	inBlock map[int]bool
+	// openBlock is set when the file ends inside an unclosed block, so an
+	// appended line belongs to it.
+	openBlock bool
+}
+
+// indexLocked returns the index, building it when needed.
+// Must be called with the lock held.
+func (h *Hosts) indexLocked() *lineIndex {
+	if h.index != nil {
+		return h.index
+	}
+
+	idx := &lineIndex{
+		byHost:  make(map[string][]int, len(h.hostFileLines)),
+		byAddr:  make(map[netip.Addr][]int, len(h.hostFileLines)),
+		inBlock: make(map[int]bool),
+	}
+	inBlock := false
+	for i := range h.hostFileLines {
+		hfl := &h.hostFileLines[i]
+		if _, begin, ok := parseBlockMarker(*hfl); ok {
+			inBlock = begin
+			continue
+		}
+		if hfl.LineType == ADDRESS {
+			idx.addLine(i, hfl, inBlock)
+		}
+	}
+	idx.openBlock = inBlock
+
+	h.index = idx
+	return idx
+}
+
+// invalidateIndexLocked drops the index after lines were inserted, removed
+// or reordered. Must be called with the lock held.
+func (h *Hosts) invalidateIndexLocked() {
+	h.index = nil
+}
+
+// appendLineLocked appends an address line, keeping the index current.
+// Must be called with the lock held.
+func (h *Hosts) appendLineLocked(hfl HostFileLine) {
+	h.hostFileLines = append(h.hostFileLines, hfl)
+	if h.index != nil {
+		i := len(h.hostFileLines) - 1
+		h.index.addLine(i, &h.hostFileLines[i], h.index.openBlock)
+	}
+}
+
+// addLine records address line i. Lines must be added in ascending order.
+func (x *lineIndex) addLine(i int, hfl *HostFileLine, inBlock bool) {
+	for _, hn := range hfl.Hostnames {
+		if pos := x.byHost[hn]; len(pos) == 0 || pos[len(pos)-1] != i {
+			x.byHost[hn] = append(pos, i)
+		}
+	}
+	if hfl.addr.IsValid() {
+		x.byAddr[hfl.addr] = append(x.byAddr[hfl.addr], i)
+	}
+	if inBlock {
+		x.inBlock[i] = true
+	}
+}
+
+// addHost records that line i now holds host.
+func (x *lineIndex) addHost(i int, host string) {
+	pos := x.byHost[host]
+	if at, found := slices.BinarySearch(pos, i); !found {
+		x.byHost[host] = slices.Insert(pos, at, i)
+	}
+}
+
+// removeHost records that line i no longer holds host.
+func (x *lineIndex) removeHost(i int, host string) {
+	pos := x.byHost[host]
+	if at, found := slices.BinarySearch(pos, i); found {
+		pos = slices.Delete(pos, at, at+1)
+		if len(pos) == 0 {
+			delete(x.byHost, host)
+			return
+		}
+		x.byHost[host] = pos
+	}
+}
+
+// hostLines returns the ascending positions of the lines holding any of the
+// given hostname forms. Must be called with the lock held.
+func (h *Hosts) hostLines(canonical, lower string) []int {
+	idx := h.indexLocked()
+	if canonical == lower {
+		return idx.byHost[canonical]
+	}
+
+	pos := slices.Concat(idx.byHost[canonical], idx.byHost[lower])
+	slices.Sort(pos)
+	return slices.Compact(pos)
+}
+
+// addressLines returns the ascending positions of the lines matching m.
+// Must be called with the lock held.
+func (h *Hosts) addressLines(m addressMatcher) []int {
+	if m.addr.IsValid() {
+		return h.indexLocked().byAddr[m.addr]
+	}
+
+	var pos []int
+	for i := range h.hostFileLines {
+		if m.matches(&h.hostFileLines[i]) {
+			pos = append(pos, i)
+		}
+	}
+	return pos
+}
+
+// removeHostsAtLocked removes the hostnames matching any of names from the
+// lines at positions, dropping lines left empty. Must be called with the
+// lock held.
+func (h *Hosts) removeHostsAtLocked(positions []int, names ...string) {
+	drop := make(map[string]bool, len(names))
+	for _, name := range names {
+		drop[name] = true
+	}
+
+	emptied := false
+	for _, i := range slices.Clone(positions) {
+		hfl := &h.hostFileLines[i]
+		kept := hfl.Hostnames[:0]
+		for _, hn := range hfl.Hostnames {
+			if !drop[hn] {
+				kept = append(kept, hn)
+			} else if h.index != nil {
+				h.index.removeHost(i, hn)
+			}
+		}
+		if len(kept) == len(hfl.Hostnames) {
+			continue
+		}
+		clear(hfl.Hostnames[len(kept):])
+		hfl.Hostnames = kept
+		hfl.pristine = false
+		emptied = emptied || len(kept) == 0
+	}
+
+	if emptied {
+		h.dropEmptyLinesLocked()
+	}
+}
+
+// dropEmptyLinesLocked removes address lines without hostnames.
+// Must be called with the lock held.
+func (h *Hosts) dropEmptyLinesLocked() {
+	h.hostFileLines = slices.DeleteFunc(h.hostFileLines, func(hfl HostFileLine) bool {
+		return hfl.LineType == ADDRESS && len(hfl.Hostnames) == 0
+	})
+	h.invalidateIndexLocked()
+}
+
+// deleteLinesLocked removes the lines for which del returns true in one
+// pass. Must be called with the lock held.
+func (h *Hosts) deleteLinesLocked(del func(*HostFileLine) bool) {
+	kept := h.hostFileLines[:0]
+	for i := range h.hostFileLines {
+		if !del(&h.hostFileLines[i]) {
+			kept = append(kept, h.hostFileLines[i])
+		}
+	}
+	if len(kept) == len(h.hostFileLines) {
+		return
+	}
+
+	clear(h.hostFileLines[len(kept):])
+	h.hostFileLines = kept
+	h.invalidateIndexLocked()
+}
+
+// deleteHostnameLocked removes hostname hidx from line i, dropping the line
+// when it is left empty. Must be called with the lock held.
+func (h *Hosts) deleteHostnameLocked(i, hidx int) {
+	hfl := &h.hostFileLines[i]
+	hn := hfl.Hostnames[hidx]
+	hfl.Hostnames = slices.Delete(hfl.Hostnames, hidx, hidx+1)
+	hfl.pristine = false
+
+	switch {
+	case len(hfl.Hostnames) == 0:
+		h.hostFileLines = slices.Delete(h.hostFileLines, i, i+1)
+		h.invalidateIndexLocked()
+	case h.index != nil && !slices.Contains(hfl.Hostnames, hn):
+		h.index.removeHost(i, hn)
+	}
+}
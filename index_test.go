@@ -0,0 +1,119 @@
+package txeh
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	"pgregory.net/rapid"
+)
+
+// checkIndex fails when the cached index differs from one built from scratch.
+func checkIndex(t interface {
+	Helper()
+	Fatalf(format string, args ...any)
+}, h *Hosts,
+) {
+	t.Helper()
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.index == nil {
+		return
+	}
+	got := h.index
+	h.index = nil
+	want := h.indexLocked()
+	h.index = got
+
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("stale index:\n got %+v\nwant %+v\nfile:\n%s", got, want, h.renderLocked())
+	}
+}
+
+func TestIndex_StaysConsistentAcrossMutations(t *testing.T) {
+	t.Parallel()
+
+	rapid.Check(t, func(t *rapid.T) {
+		// Given: a hosts file with a managed block and an indexed lookup
+		raw := "127.0.0.1 localhost\n" +
+			"# BEGIN txeh:app\n10.0.0.1 app-1.local\n# END txeh:app\n" +
+			"10.0.0.1 app-2.local app-2.local\n"
+		h, err := NewHosts(&HostsConfig{RawText: &raw, MaxHostsPerLine: 3})
+		if err != nil {
+			t.Fatal(err)
+		}
+		h.HostAddressLookup("localhost", IPFamilyV4)
+
+		ips := rapid.SampledFrom([]string{"10.0.0.1", "10.0.0.2", "::ffff:10.0.0.2", "192.168.1.1"})
+		comments := rapid.SampledFrom([]string{"", "svc"})
+
+		// When: random mutations run
+		steps := rapid.IntRange(1, 30).Draw(t, "steps")
+		for range steps {
+			host := hostnameGen().Draw(t, "host")
+			switch rapid.IntRange(0, 9).Draw(t, "op") {
+			case 0, 1, 2:
+				h.AddHostWithComment(ips.Draw(t, "ip"), host, comments.Draw(t, "comment"))
+			case 3:
+				h.RemoveHost(host)
+			case 4:
+				h.RemoveFirstHost(host)
+			case 5:
+				h.RemoveHosts([]string{host, hostnameGen().Draw(t, "host2")})
+			case 6:
+				h.RemoveFirstAddress(ips.Draw(t, "ip"))
+			case 7:
+				h.RemoveByComment(comments.Draw(t, "comment"))
+			case 8:
+				_ = h.AddHostWithOptions(ips.Draw(t, "ip"), host, AddOptions{Policy: AddressPolicyAllowMultiple})
+			case 9:
+				_ = h.AddHostWithTTL(ips.Draw(t, "ip"), host, time.Minute)
+				h.PruneExpired(time.Now().Add(time.Hour))
+			}
+
+			// Then: the index matches the file after every step
+			checkIndex(t, h)
+		}
+	})
+}
+
+func TestIndex_AddSkipsBlockAfterIndexBuilt(t *testing.T) {
+	t.Parallel()
+
+	// Given: an indexed file whose only 10.0.0.1 line is in a managed block
+	h := newRawHosts(t, "# BEGIN txeh:app\n10.0.0.1 app.local\n# END txeh:app\n")
+	h.HostAddressLookup("app.local", IPFamilyV4)
+
+	// When: a host is added to that address
+	h.AddHost("10.0.0.1", "other.local")
+
+	// Then: it goes on a new line outside the block
+	want := "# BEGIN txeh:app\n10.0.0.1         app.local\n# END txeh:app\n10.0.0.1         other.local\n"
+	if got := h.RenderHostsFile(); got != want {
+		t.Errorf("RenderHostsFile() = %q, want %q", got, want)
+	}
+	checkIndex(t, h)
+}
+
+func TestIndex_RemoveHostsBulk(t *testing.T) {
+	t.Parallel()
+
+	// Given: hosts spread over several lines, some sharing lines
+	h := newRawHosts(t, "10.0.0.1 a b\n10.0.0.2 c\n10.0.0.3 b d\n10.0.0.4 e\n")
+
+	// When: several hosts are removed at once
+	h.RemoveHosts([]string{"b", "c", "E"})
+
+	// Then: emptied lines are dropped and the rest keep their order
+	want := "10.0.0.1         a\n10.0.0.3         d\n"
+	if got := h.RenderHostsFile(); got != want {
+		t.Errorf("RenderHostsFile() = %q, want %q", got, want)
+	}
+	if got := h.ListHostsByIP("10.0.0.3"); !slices.Equal(got, []string{"d"}) {
+		t.Errorf("ListHostsByIP(10.0.0.3) = %v, want [d]", got)
+	}
+	checkIndex(t, h)
+}
@@ -0,0 +1,58 @@
+package txeh
+
+import (
+	"fmt"
+	"io"
+)
+
+// utf8BOM is the byte order mark some Windows editors put at the start of
+// the hosts file.
+const utf8BOM = "\uFEFF"
+
+// LineEnding selects the line terminator used when rendering the hosts file.
+type LineEnding int
+
+// Line ending constants for HostsConfig.LineEnding.
+const (
+	LineEndingAuto LineEnding = iota // Reproduce the style of the input (LF for empty input).
+	LineEndingLF                     // Force "\n".
+	LineEndingCRLF                   // Force "\r\n".
+)
+
+// fileFormat records the byte-level layout of the parsed input so rendering
+// can reproduce it.
+type fileFormat struct {
+	crlf         bool
+	bom          bool
+	finalNewline bool
+}
+
+// parseLocked parses r into the in-memory state and records its format.
+// Must be called with the lock held.
+func (h *Hosts) parseLocked(r io.Reader) error {
+	hfl, format, err := readHosts(r)
+	if err != nil {
+		return fmt.Errorf("read hosts: %w", err)
+	}
+
+	h.hostFileLines = hfl
+	h.invalidateIndexLocked()
+	h.format = format
+
+	return nil
+}
+
+// lineTerminator returns the line terminator to render with.
+func (h *Hosts) lineTerminator() string {
+	le := LineEndingAuto
+	if h.HostsConfig != nil {
+		le = h.LineEnding
+	}
+
+	switch {
+	case le == LineEndingCRLF, le == LineEndingAuto && h.format.crlf:
+		return "\r\n"
+	default:
+		return "\n"
+	}
+}
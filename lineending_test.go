@@ -0,0 +1,95 @@
+package txeh
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDetectFileFormat(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  fileFormat
+	}{
+		{"empty", "", fileFormat{finalNewline: true}},
+		{"lf", "a\nb\n", fileFormat{finalNewline: true}},
+		{"crlf", "a\r\nb\r\n", fileFormat{crlf: true, finalNewline: true}},
+		{"mostly crlf", "a\r\nb\r\nc\n", fileFormat{crlf: true, finalNewline: true}},
+		{"mostly lf", "a\nb\nc\r\n", fileFormat{finalNewline: true}},
+		{"no final newline", "a\r\nb", fileFormat{crlf: true}},
+		{"bom", utf8BOM + "a\n", fileFormat{bom: true, finalNewline: true}},
+		{"bom only", utf8BOM, fileFormat{bom: true, finalNewline: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, got, _ := readHosts(strings.NewReader(tt.input)); got != tt.want {
+				t.Errorf("readHosts(%q) format = %+v, want %+v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+// Given a Windows hosts file with a BOM, CRLF and no final newline
+// When it is loaded, changed and saved
+// Then all three properties survive and the BOM is not part of the first line.
+func TestLineEnding_WindowsFileRoundTrip(t *testing.T) {
+	path := writeHostsFile(t, utf8BOM+"# Copyright (c) Microsoft Corp.\r\n127.0.0.1 localhost\r\n::1 localhost")
+
+	hosts, err := NewHosts(&HostsConfig{ReadFilePath: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := hosts.GetHostFileLines()
+	if lines[0].Raw != "# Copyright (c) Microsoft Corp." {
+		t.Errorf("BOM leaked into first line: %q", lines[0].Raw)
+	}
+
+	hosts.AddHost("10.0.0.1", "new")
+	if err := hosts.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := utf8BOM + "# Copyright (c) Microsoft Corp.\r\n" +
+		"127.0.0.1        localhost\r\n" +
+		"::1              localhost\r\n" +
+		"10.0.0.1         new"
+	if got := readHostsFile(t, path); got != want {
+		t.Errorf("got %q\nwant %q", got, want)
+	}
+}
+
+// Given a BOM at the start of an address line
+// When parsed
+// Then the address is recognized.
+func TestParseHostsFromString_StripsBOM(t *testing.T) {
+	lines, err := ParseHostsFromString(utf8BOM + "127.0.0.1 localhost\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines[0].LineType != ADDRESS || lines[0].Address != testIPv4Localhost {
+		t.Errorf("unexpected first line %+v", lines[0])
+	}
+}
+
+// Given a LineEnding override
+// When the file is rendered
+// Then the forced terminator is used regardless of the input.
+func TestLineEnding_Override(t *testing.T) {
+	crlf := "127.0.0.1 localhost\r\n10.0.0.1 a\r\n"
+	hosts, err := NewHosts(&HostsConfig{RawText: &crlf, LineEnding: LineEndingLF})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := hosts.RenderHostsFile(); strings.Contains(got, "\r") {
+		t.Errorf("LineEndingLF rendered CR: %q", got)
+	}
+
+	lf := "127.0.0.1 localhost\n10.0.0.1 a\n"
+	hosts, err = NewHosts(&HostsConfig{RawText: &lf, LineEnding: LineEndingCRLF})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := hosts.RenderHostsFile(); strings.Count(got, "\r\n") != 2 {
+		t.Errorf("LineEndingCRLF not applied: %q", got)
+	}
+}
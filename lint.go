@@ -0,0 +1,144 @@
+package txeh
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"slices"
+)
+
+// Severity classifies a lint Diagnostic.
+type Severity string
+
+// Severity levels reported by Lint.
+const (
+	SeverityError   Severity = "error"   // The file is malformed or will not resolve as intended.
+	SeverityWarning Severity = "warning" // The file works but is probably not what was meant.
+)
+
+// Diagnostic codes reported by Lint.
+const (
+	LintDuplicateEntry   = "duplicate-entry"   // The same hostname is mapped to the same address again.
+	LintShadowedHost     = "shadowed-host"     // An earlier line maps the hostname to another address of the same family.
+	LintUnknownLine      = "unknown-line"      // The line is neither blank, a comment nor an address line.
+	LintInvalidAddress   = "invalid-address"   // The address is not an IP literal.
+	LintHostnameTooLong  = "hostname-too-long" // A hostname or one of its labels exceeds the RFC 1123 limits.
+	LintTooManyHosts     = "too-many-hosts"    // The line has more hostnames than Windows resolves.
+	LintMissingLocalhost = "missing-localhost" // No line maps localhost to a loopback address.
+)
+
+// Diagnostic is a problem found by Lint.
+type Diagnostic struct {
+	// Line is the 1-based line number in the rendered file, or 0 for
+	// problems that concern the whole file.
+	Line     int      `json:"line"`
+	Severity Severity `json:"severity"`
+	Code     string   `json:"code"`
+	Message  string   `json:"message"`
+}
+
+// String formats the diagnostic as "line 3: error: message (code)".
+func (d Diagnostic) String() string {
+	if d.Line == 0 {
+		return fmt.Sprintf("%s: %s (%s)", d.Severity, d.Message, d.Code)
+	}
+	return fmt.Sprintf("line %d: %s: %s (%s)", d.Line, d.Severity, d.Message, d.Code)
+}
+
+// Lint checks the hosts file for common mistakes and returns the problems
+// found, ordered by line with file-level problems first. Diagnostics with SeverityError mark lines that are
+// malformed; warnings mark entries that are redundant, unreachable or
+// unportable. Lint does not change anything.
+func (h *Hosts) Lint() []Diagnostic {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var diags []Diagnostic
+	add := func(line int, sev Severity, code, format string, args ...any) {
+		diags = append(diags, Diagnostic{Line: line, Severity: sev, Code: code, Message: fmt.Sprintf(format, args...)})
+	}
+
+	// seen is keyed by address family and hostname.
+	seen := make(map[IPFamily]map[string]hostMapping)
+	hasLocalhost := false
+
+	for i, hfl := range h.hostFileLines {
+		line := i + 1
+
+		switch hfl.LineType {
+		case UNKNOWN:
+			add(line, SeverityError, LintUnknownLine, "cannot parse %q as an address line", hfl.Trimmed)
+			continue
+		case ADDRESS:
+		default:
+			continue
+		}
+
+		if !hfl.addr.IsValid() {
+			add(line, SeverityError, LintInvalidAddress, "%q is not an IP address", hfl.Address)
+		}
+
+		if len(hfl.Hostnames) > DefaultMaxHostsPerLineWindows {
+			add(line, SeverityWarning, LintTooManyHosts, "%d hostnames on one line; Windows resolves at most %d",
+				len(hfl.Hostnames), DefaultMaxHostsPerLineWindows)
+		}
+
+		for _, hn := range hfl.Hostnames {
+			if err := ValidateHostname(hn); errors.Is(err, ErrHostnameTooLong) || errors.Is(err, ErrLabelTooLong) {
+				add(line, SeverityError, LintHostnameTooLong, "hostname %q: %v", hn, errors.Unwrap(err))
+			}
+			if !hfl.addr.IsValid() {
+				continue
+			}
+			if hn == "localhost" && hfl.addr.IsLoopback() {
+				hasLocalhost = true
+			}
+
+			family := IPFamilyV6
+			if hfl.addr.Is4() {
+				family = IPFamilyV4
+			}
+			if seen[family] == nil {
+				seen[family] = make(map[string]hostMapping)
+			}
+			first, ok := seen[family][hn]
+			switch {
+			case !ok:
+				seen[family][hn] = hostMapping{addr: hfl.addr, address: hfl.Address, line: line}
+			case first.addr == hfl.addr:
+				add(line, SeverityWarning, LintDuplicateEntry, "%s %s is already mapped on line %d",
+					hfl.Address, hn, first.line)
+			default:
+				add(line, SeverityWarning, LintShadowedHost, "%s is mapped to %s on line %d, so %s is never used",
+					hn, first.address, first.line, hfl.Address)
+			}
+		}
+	}
+
+	if !hasLocalhost {
+		diags = slices.Insert(diags, 0, Diagnostic{
+			Severity: SeverityWarning,
+			Code:     LintMissingLocalhost,
+			Message:  "no entry maps localhost to a loopback address",
+		})
+	}
+
+	return diags
+}
+
+// hostMapping is where a hostname was first mapped for one address family.
+type hostMapping struct {
+	addr    netip.Addr
+	address string
+	line    int
+}
+
+// HasLintErrors reports whether any diagnostic has SeverityError.
+func HasLintErrors(diags []Diagnostic) bool {
+	for _, d := range diags {
+		if d.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,75 @@
+package txeh
+
+import (
+	"strings"
+	"testing"
+)
+
+// Given a hosts file with one problem of every kind
+// When Lint is called
+// Then each problem is reported once with its line, severity and code.
+func TestLint_ReportsEachProblem(t *testing.T) {
+	input := strings.Join([]string{
+		"# comment",
+		"127.0.0.1 localhost",
+		"10.0.0.1 app app",
+		"10.0.0.2 app",
+		"fd00::1 app",
+		"garbage",
+		"999.1.1.1 bad",
+		"10.0.0.3 " + strings.Repeat("a", 64) + ".local",
+		"10.0.0.4 h1 h2 h3 h4 h5 h6 h7 h8 h9 h10",
+	}, "\n") + "\n"
+	hosts := newRawHosts(t, input)
+
+	got := hosts.Lint()
+	want := []Diagnostic{
+		{Line: 3, Severity: SeverityWarning, Code: LintDuplicateEntry},
+		{Line: 4, Severity: SeverityWarning, Code: LintShadowedHost},
+		{Line: 6, Severity: SeverityError, Code: LintUnknownLine},
+		{Line: 7, Severity: SeverityError, Code: LintInvalidAddress},
+		{Line: 8, Severity: SeverityError, Code: LintHostnameTooLong},
+		{Line: 9, Severity: SeverityWarning, Code: LintTooManyHosts},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Lint() = %v, want %d diagnostics", got, len(want))
+	}
+	for i, w := range want {
+		g := got[i]
+		if g.Line != w.Line || g.Severity != w.Severity || g.Code != w.Code || g.Message == "" {
+			t.Errorf("diagnostic %d = %+v, want line %d %s %s", i, g, w.Line, w.Severity, w.Code)
+		}
+	}
+	if !HasLintErrors(got) {
+		t.Error("HasLintErrors() = false, want true")
+	}
+}
+
+// Given a hosts file without a loopback localhost entry
+// When Lint is called
+// Then a file-level missing-localhost warning comes first.
+func TestLint_MissingLocalhost(t *testing.T) {
+	hosts := newRawHosts(t, "10.0.0.1 localhost\n10.0.0.2 app\n")
+
+	got := hosts.Lint()
+	if len(got) != 1 || got[0].Code != LintMissingLocalhost || got[0].Line != 0 {
+		t.Fatalf("Lint() = %v, want missing-localhost", got)
+	}
+	if HasLintErrors(got) {
+		t.Error("missing localhost should be a warning")
+	}
+	if s := got[0].String(); !strings.HasPrefix(s, "warning: ") {
+		t.Errorf("String() = %q", s)
+	}
+}
+
+// Given a clean hosts file
+// When Lint is called
+// Then nothing is reported.
+func TestLint_CleanFile(t *testing.T) {
+	hosts := newRawHosts(t, "127.0.0.1 localhost\n::1 localhost\n10.0.0.1 app # dev\n")
+
+	if got := hosts.Lint(); len(got) != 0 {
+		t.Errorf("Lint() = %v, want none", got)
+	}
+}
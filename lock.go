@@ -0,0 +1,179 @@
+package txeh
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// ErrLocked is returned when the hosts file lock is held by another process
+// and could not be acquired before the timeout expired. Check for it with
+// errors.Is.
+var ErrLocked = errors.New("hosts file is locked by another process")
+
+// lockSuffix is appended to the hosts file path to form the sidecar lock file.
+const lockSuffix = ".lock"
+
+// lockPollInterval is how often a waiting FileLock retries a held lock.
+const lockPollInterval = 25 * time.Millisecond
+
+// FileLock is an advisory, cross-process lock on a hosts file. It is held on a
+// sidecar file (the hosts file path plus ".lock") so the hosts file itself can
+// still be replaced atomically while the lock is held. Every process that
+// modifies the file must use the same lock for it to be effective.
+type FileLock struct {
+	path string
+	f    *os.File
+}
+
+// LockHostsFile acquires the advisory lock for the hosts file at path, waiting
+// up to timeout for another holder to release it. A timeout of zero or less
+// makes a single attempt. The returned error wraps ErrLocked when the lock is
+// still held by someone else after the timeout.
+//
+// Symlinks in path are resolved so /etc/hosts and /private/etc/hosts on macOS
+// share the same lock.
+func LockHostsFile(path string, timeout time.Duration) (*FileLock, error) {
+	target, err := resolveWriteTarget(path)
+	if err != nil {
+		return nil, err
+	}
+	lockPath := target + lockSuffix
+
+	f, err := os.OpenFile(lockPath, os.O_RDWR|os.O_CREATE, defaultHostsFileMode) // #nosec G302 G304 -- sidecar of the hosts file, same permissions
+	if err != nil {
+		return nil, fmt.Errorf("open lock file %s: %w", lockPath, err)
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		ok, err := tryLockFile(f)
+		if err != nil {
+			_ = f.Close()
+			return nil, fmt.Errorf("lock %s: %w", lockPath, err)
+		}
+		if ok {
+			return &FileLock{path: lockPath, f: f}, nil
+		}
+		if !time.Now().Before(deadline) {
+			_ = f.Close()
+			return nil, fmt.Errorf("lock %s (waited %s): %w", lockPath, timeout, ErrLocked)
+		}
+		time.Sleep(lockPollInterval)
+	}
+}
+
+// Path returns the path of the sidecar lock file.
+func (l *FileLock) Path() string {
+	return l.path
+}
+
+// Unlock releases the lock. Calling Unlock more than once is a no-op.
+// The sidecar file is left in place; removing it would race with processes
+// that have already opened it.
+func (l *FileLock) Unlock() error {
+	if l == nil || l.f == nil {
+		return nil
+	}
+	f := l.f
+	l.f = nil
+
+	err := unlockFile(f)
+	if cerr := f.Close(); err == nil && cerr != nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("unlock %s: %w", l.path, err)
+	}
+
+	return nil
+}
+
+// AcquireLock takes the cross-process lock on the configured write path,
+// waiting up to timeout. The lock is held until the next Save or SaveAs of that
+// path completes, or until ReleaseLock is called. Acquiring a lock that this
+// instance already holds is a no-op.
+//
+// Holding the lock from Reload through Save makes the read-modify-write cycle
+// safe against other txeh users (kubefwd, the txeh CLI, ...) that lock the
+// same file.
+func (h *Hosts) AcquireLock(timeout time.Duration) error {
+	if src := h.nonFileSource(); src != "" {
+		return fmt.Errorf("cannot lock a hosts file loaded from %s", src)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.acquireLockLocked(timeout)
+}
+
+// ReleaseLock releases a lock taken by AcquireLock, NewHosts or Reload without
+// saving. It is a no-op when no lock is held.
+func (h *Hosts) ReleaseLock() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.releaseLockLocked()
+}
+
+// acquireLockLocked takes the write path lock if it is not already held.
+// Must be called with h.mu held.
+func (h *Hosts) acquireLockLocked(timeout time.Duration) error {
+	if h.fileLock != nil {
+		return nil
+	}
+
+	l, err := LockHostsFile(h.WriteFilePath, timeout)
+	if err != nil {
+		return err
+	}
+	h.fileLock = l
+
+	return nil
+}
+
+// releaseLockLocked releases the held lock, if any. Must be called with h.mu held.
+func (h *Hosts) releaseLockLocked() error {
+	l := h.fileLock
+	h.fileLock = nil
+
+	return l.Unlock()
+}
+
+// lockForWriteLocked ensures the lock covering fileName is held for the
+// duration of a write when LockTimeout is configured. It returns a function
+// that releases the lock afterwards. A lock already held on the write path is
+// released as well, ending the read-modify-write cycle it protected.
+// Must be called with h.mu held.
+func (h *Hosts) lockForWriteLocked(fileName string) (release func() error, err error) {
+	if h.LockTimeout <= 0 && h.fileLock == nil {
+		return func() error { return nil }, nil
+	}
+
+	if h.fileLock != nil && sameFile(fileName, h.WriteFilePath) {
+		return h.releaseLockLocked, nil
+	}
+
+	if h.LockTimeout <= 0 {
+		return func() error { return nil }, nil
+	}
+
+	l, err := LockHostsFile(fileName, h.LockTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	return l.Unlock, nil
+}
+
+// sameFile reports whether two paths resolve to the same write target.
+func sameFile(a, b string) bool {
+	ra, errA := resolveWriteTarget(a)
+	rb, errB := resolveWriteTarget(b)
+	if errA != nil || errB != nil {
+		return a == b
+	}
+	return ra == rb
+}
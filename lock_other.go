@@ -0,0 +1,21 @@
+//go:build !darwin && !dragonfly && !freebsd && !illumos && !linux && !netbsd && !openbsd && !windows
+
+package txeh
+
+import (
+	"errors"
+	"os"
+)
+
+// errLockUnsupported is returned on platforms without an advisory lock primitive.
+var errLockUnsupported = errors.New("file locking is not supported on this platform")
+
+// tryLockFile returns an error on unsupported platforms.
+func tryLockFile(_ *os.File) (bool, error) {
+	return false, errLockUnsupported
+}
+
+// unlockFile returns an error on unsupported platforms.
+func unlockFile(_ *os.File) error {
+	return errLockUnsupported
+}
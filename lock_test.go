@@ -0,0 +1,213 @@
+package txeh
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newLockedHostsFile writes a hosts file into a temp dir and returns its path.
+func newLockedHostsFile(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "hosts")
+	if err := os.WriteFile(path, []byte(testHostsLocalhost), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// Given a hosts file locked by one FileLock
+// When a second lock is attempted with a short timeout
+// Then it fails with an error wrapping ErrLocked.
+func TestLockHostsFile_Contended_ReturnsErrLocked(t *testing.T) {
+	path := newLockedHostsFile(t)
+
+	first, err := LockHostsFile(path, 0)
+	if err != nil {
+		t.Fatalf("first lock: %v", err)
+	}
+	defer func() { _ = first.Unlock() }()
+
+	start := time.Now()
+	_, err = LockHostsFile(path, 100*time.Millisecond)
+	if !errors.Is(err, ErrLocked) {
+		t.Fatalf("expected ErrLocked, got %v", err)
+	}
+	if time.Since(start) < 100*time.Millisecond {
+		t.Error("second lock did not wait for the timeout")
+	}
+	if first.Path() != path+lockSuffix {
+		t.Errorf("Path() = %q, want %q", first.Path(), path+lockSuffix)
+	}
+}
+
+// Given a held lock that is released while another caller waits
+// When the waiter's timeout has not expired
+// Then the waiter acquires the lock.
+func TestLockHostsFile_WaitsForRelease(t *testing.T) {
+	path := newLockedHostsFile(t)
+
+	first, err := LockHostsFile(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		_ = first.Unlock()
+	}()
+
+	second, err := LockHostsFile(path, 2*time.Second)
+	if err != nil {
+		t.Fatalf("waiter did not get the lock: %v", err)
+	}
+	if err := second.Unlock(); err != nil {
+		t.Errorf("Unlock() error: %v", err)
+	}
+	// A second Unlock is a no-op.
+	if err := second.Unlock(); err != nil {
+		t.Errorf("double Unlock() error: %v", err)
+	}
+}
+
+// Given HostsConfig.LockTimeout is set
+// When NewHosts loads the file
+// Then the lock is held until Save completes.
+func TestHosts_LockTimeout_HeldFromLoadUntilSave(t *testing.T) {
+	path := newLockedHostsFile(t)
+
+	hosts, err := NewHosts(&HostsConfig{ReadFilePath: path, LockTimeout: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := LockHostsFile(path, 0); !errors.Is(err, ErrLocked) {
+		t.Fatalf("lock should be held after NewHosts, got %v", err)
+	}
+
+	hosts.AddHost(testIPv4Alt, "locked")
+	if err := hosts.Save(); err != nil {
+		t.Fatalf("Save() error: %v", err)
+	}
+
+	l, err := LockHostsFile(path, 0)
+	if err != nil {
+		t.Fatalf("lock should be free after Save, got %v", err)
+	}
+	_ = l.Unlock()
+}
+
+// Given another process holds the lock
+// When NewHosts is called with LockTimeout
+// Then it fails with ErrLocked instead of reading the file.
+func TestHosts_LockTimeout_NewHostsBlockedByHolder(t *testing.T) {
+	path := newLockedHostsFile(t)
+
+	holder, err := LockHostsFile(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = holder.Unlock() }()
+
+	_, err = NewHosts(&HostsConfig{ReadFilePath: path, LockTimeout: 50 * time.Millisecond})
+	if !errors.Is(err, ErrLocked) {
+		t.Fatalf("expected ErrLocked, got %v", err)
+	}
+}
+
+// Given a Hosts instance without a held lock and LockTimeout set
+// When Reload is called
+// Then the lock is taken, and ReleaseLock frees it without saving.
+func TestHosts_Reload_TakesLock_ReleaseLock(t *testing.T) {
+	path := newLockedHostsFile(t)
+
+	hosts, err := NewHosts(&HostsConfig{ReadFilePath: path, LockTimeout: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := hosts.ReleaseLock(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := hosts.Reload(); err != nil {
+		t.Fatalf("Reload() error: %v", err)
+	}
+	if _, err := LockHostsFile(path, 0); !errors.Is(err, ErrLocked) {
+		t.Fatalf("lock should be held after Reload, got %v", err)
+	}
+
+	if err := hosts.ReleaseLock(); err != nil {
+		t.Fatalf("ReleaseLock() error: %v", err)
+	}
+	l, err := LockHostsFile(path, 0)
+	if err != nil {
+		t.Fatalf("lock should be free after ReleaseLock, got %v", err)
+	}
+	_ = l.Unlock()
+}
+
+// Given locking is not configured
+// When AcquireLock is called explicitly
+// Then the lock is held until Save, and a RawText instance refuses to lock.
+func TestHosts_AcquireLock_Explicit(t *testing.T) {
+	path := newLockedHostsFile(t)
+
+	hosts, err := NewHosts(&HostsConfig{ReadFilePath: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := hosts.AcquireLock(time.Second); err != nil {
+		t.Fatalf("AcquireLock() error: %v", err)
+	}
+	if err := hosts.AcquireLock(time.Second); err != nil {
+		t.Fatalf("re-acquiring a held lock should be a no-op, got %v", err)
+	}
+	if _, err := LockHostsFile(path, 0); !errors.Is(err, ErrLocked) {
+		t.Fatalf("lock should be held, got %v", err)
+	}
+	if err := hosts.Save(); err != nil {
+		t.Fatal(err)
+	}
+	l, err := LockHostsFile(path, 0)
+	if err != nil {
+		t.Fatalf("lock should be released by Save, got %v", err)
+	}
+	_ = l.Unlock()
+
+	raw := testHostsLocalhost
+	rawHosts, _ := NewHosts(&HostsConfig{RawText: &raw})
+	if err := rawHosts.AcquireLock(0); err == nil || !strings.Contains(err.Error(), "RawText") {
+		t.Errorf("expected RawText error, got %v", err)
+	}
+}
+
+// Given a lock held by another process and LockTimeout set
+// When SaveAs is called without holding the lock
+// Then the write fails with ErrLocked and the file is untouched.
+func TestHosts_SaveAs_WaitsForLock(t *testing.T) {
+	path := newLockedHostsFile(t)
+
+	hosts, err := NewHosts(&HostsConfig{ReadFilePath: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hosts.LockTimeout = 50 * time.Millisecond
+	hosts.AddHost(testIPv4Alt, "blocked")
+
+	holder, err := LockHostsFile(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = holder.Unlock() }()
+
+	if err := hosts.Save(); !errors.Is(err, ErrLocked) {
+		t.Fatalf("expected ErrLocked, got %v", err)
+	}
+	content, _ := os.ReadFile(path) // #nosec G304 -- test file
+	if strings.Contains(string(content), "blocked") {
+		t.Error("file was written while locked by another holder")
+	}
+}
@@ -0,0 +1,57 @@
+//go:build windows
+
+package txeh
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// LockFileEx flags and the error returned when the range is already locked.
+// Source: https://learn.microsoft.com/en-us/windows/win32/api/fileapi/nf-fileapi-lockfileex
+const (
+	lockfileFailImmediately = 0x00000001
+	lockfileExclusiveLock   = 0x00000002
+	errorLockViolation      = syscall.Errno(33)
+)
+
+var (
+	modKernel32      = syscall.NewLazyDLL("kernel32.dll")
+	procLockFileEx   = modKernel32.NewProc("LockFileEx")
+	procUnlockFileEx = modKernel32.NewProc("UnlockFileEx")
+)
+
+// tryLockFile attempts a non-blocking exclusive LockFileEx on the first byte
+// of f. It reports false without an error when another handle holds the lock.
+func tryLockFile(f *os.File) (bool, error) {
+	var ol syscall.Overlapped
+	r1, _, err := procLockFileEx.Call(
+		f.Fd(),
+		uintptr(lockfileExclusiveLock|lockfileFailImmediately),
+		0, 1, 0,
+		uintptr(unsafe.Pointer(&ol)), // #nosec G103 -- required by the Win32 API
+	)
+	if r1 != 0 {
+		return true, nil
+	}
+	if errors.Is(err, errorLockViolation) {
+		return false, nil
+	}
+	return false, err
+}
+
+// unlockFile releases a lock taken by tryLockFile.
+func unlockFile(f *os.File) error {
+	var ol syscall.Overlapped
+	r1, _, err := procUnlockFileEx.Call(
+		f.Fd(),
+		0, 1, 0,
+		uintptr(unsafe.Pointer(&ol)), // #nosec G103 -- required by the Win32 API
+	)
+	if r1 == 0 {
+		return err
+	}
+	return nil
+}
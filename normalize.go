@@ -0,0 +1,173 @@
+package txeh
+
+import (
+	"cmp"
+	"maps"
+	"slices"
+)
+
+// NormalizeOptions selects what Normalize does. The zero value changes
+// nothing but the formatting of address lines.
+type NormalizeOptions struct {
+	// Merge moves the hostnames of later lines with the same address and
+	// comment onto the first such line. A hostname stays where it is when
+	// moving it would let it win over another address mapped in between.
+	Merge bool
+	// Dedupe removes hostnames already mapped to the same address on an
+	// earlier line (or earlier on the same line).
+	Dedupe bool
+	// Reflow splits lines with more hostnames than MaxHostsPerLine allows,
+	// fixing long lines written by other tools.
+	Reflow bool
+	// Sort orders address lines by IP, then first hostname, within each run
+	// of consecutive address lines. Sorting can change which line wins for a
+	// hostname mapped to several addresses; Lint reports those.
+	Sort bool
+}
+
+// Normalize rewrites the address lines of the hosts file as selected by
+// opts. Comment and blank lines stay in place, and managed blocks are
+// normalized on their own so entries never move into or out of a block.
+// Every address line is reformatted, even with PreserveFormatting set.
+func (h *Hosts) Normalize(opts NormalizeOptions) {
+	h.mutate(func(x *Hosts) { x.normalizeLocked(opts) })
+}
+
+// hostPlacement records a line a hostname was placed on.
+type hostPlacement struct {
+	line    int
+	address string
+}
+
+// normalizeLocked implements Normalize. Must be called with the lock held.
+func (h *Hosts) normalizeLocked(opts NormalizeOptions) {
+	type lineKey struct{ region, address, comment string }
+	type hostKey struct{ region, address, hostname string }
+
+	lines := h.hostFileLines
+	first := make(map[lineKey]int)
+	seen := make(map[hostKey]bool)
+	placed := make(map[string][]hostPlacement)
+	region := ""
+
+	for i := range lines {
+		hfl := &lines[i]
+		if name, begin, ok := parseBlockMarker(*hfl); ok {
+			region = ""
+			if begin {
+				region = name
+			}
+			continue
+		}
+		if hfl.LineType != ADDRESS {
+			continue
+		}
+		hfl.pristine = false
+
+		address := addressKey(hfl)
+		target, merge := first[lineKey{region, address, hfl.Comment}]
+		if !merge {
+			first[lineKey{region, address, hfl.Comment}] = i
+		}
+		merge = merge && opts.Merge
+
+		kept := make([]string, 0, len(hfl.Hostnames))
+		for _, hn := range hfl.Hostnames {
+			hk := hostKey{region, address, hn}
+			if opts.Dedupe && seen[hk] {
+				continue
+			}
+			seen[hk] = true
+
+			if merge && canMoveHost(placed[hn], target, address) {
+				lines[target].Hostnames = append(lines[target].Hostnames, hn)
+				placed[hn] = append(placed[hn], hostPlacement{line: target, address: address})
+				continue
+			}
+			kept = append(kept, hn)
+			placed[hn] = append(placed[hn], hostPlacement{line: i, address: address})
+		}
+		hfl.Hostnames = kept
+	}
+
+	lines = slices.DeleteFunc(lines, func(hfl HostFileLine) bool {
+		return hfl.LineType == ADDRESS && len(hfl.Hostnames) == 0
+	})
+
+	if limit := h.getEffectiveMaxHostsPerLine(); opts.Reflow && limit > 0 {
+		lines = reflowLines(lines, limit)
+	}
+	if opts.Sort {
+		sortAddressRuns(lines)
+	}
+
+	h.hostFileLines = lines
+	h.invalidateIndexLocked()
+}
+
+// canMoveHost reports whether a hostname can move up to line target for
+// address without a line in between mapping it to a different address.
+func canMoveHost(placements []hostPlacement, target int, address string) bool {
+	for _, p := range placements {
+		if p.line > target && p.address != address {
+			return false
+		}
+	}
+	return true
+}
+
+// reflowLines splits address lines longer than limit into consecutive lines
+// with the same address and comment.
+func reflowLines(lines HostFileLines, limit int) HostFileLines {
+	out := make(HostFileLines, 0, len(lines))
+	for _, hfl := range lines {
+		if hfl.LineType != ADDRESS || len(hfl.Hostnames) <= limit {
+			out = append(out, hfl)
+			continue
+		}
+		for chunk := range slices.Chunk(hfl.Hostnames, limit) {
+			part := hfl
+			part.Hostnames = slices.Clone(chunk)
+			part.Tags = maps.Clone(hfl.Tags)
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
+// sortAddressRuns sorts each run of consecutive address lines by IP, then
+// first hostname. Lines with an unparseable address sort last.
+func sortAddressRuns(lines HostFileLines) {
+	for start := 0; start < len(lines); {
+		if lines[start].LineType != ADDRESS {
+			start++
+			continue
+		}
+		end := start
+		for end < len(lines) && lines[end].LineType == ADDRESS {
+			end++
+		}
+		slices.SortStableFunc(lines[start:end], compareAddressLines)
+		start = end
+	}
+}
+
+// compareAddressLines orders address lines by IP, then first hostname.
+func compareAddressLines(a, b HostFileLine) int {
+	switch {
+	case !a.addr.IsValid() || !b.addr.IsValid():
+		return cmp.Compare(boolRank(!a.addr.IsValid()), boolRank(!b.addr.IsValid()))
+	case a.addr != b.addr:
+		return a.addr.Compare(b.addr)
+	default:
+		return cmp.Compare(a.Hostnames[0], b.Hostnames[0])
+	}
+}
+
+// boolRank orders false before true.
+func boolRank(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,128 @@
+package txeh
+
+import (
+	"strings"
+	"testing"
+)
+
+var allNormalizeOptions = NormalizeOptions{Merge: true, Dedupe: true, Reflow: true, Sort: true}
+
+// Given lines for the same address and comment split across the file
+// When Normalize merges and dedupes
+// Then the hostnames join the first line and duplicates are dropped, while
+// comments and blank lines stay in place.
+func TestNormalize_MergeAndDedupe(t *testing.T) {
+	hosts := newRawHosts(t, strings.Join([]string{
+		"# header",
+		"127.0.0.1 localhost",
+		"10.0.0.1 a b a",
+		"",
+		"10.0.0.1 c b",
+		"10.0.0.1 d # dev",
+		"10.0.0.1 e # dev",
+	}, "\n")+"\n")
+
+	hosts.Normalize(NormalizeOptions{Merge: true, Dedupe: true})
+
+	want := strings.Join([]string{
+		"# header",
+		"127.0.0.1        localhost",
+		"10.0.0.1         a b c",
+		"",
+		"10.0.0.1         d e # dev",
+	}, "\n") + "\n"
+	if got := hosts.RenderHostsFile(); got != want {
+		t.Errorf("RenderHostsFile() =\n%s\nwant:\n%s", got, want)
+	}
+}
+
+// Given a hostname mapped to another address between two lines of one address
+// When Normalize merges
+// Then that hostname stays on its line so resolution does not change.
+func TestNormalize_MergeKeepsShadowing(t *testing.T) {
+	hosts := newRawHosts(t, "10.0.0.1 a\n10.0.0.2 b\n10.0.0.1 b c\n")
+
+	hosts.Normalize(NormalizeOptions{Merge: true})
+
+	want := "10.0.0.1         a c\n10.0.0.2         b\n10.0.0.1         b\n"
+	if got := hosts.RenderHostsFile(); got != want {
+		t.Errorf("RenderHostsFile() =\n%s\nwant:\n%s", got, want)
+	}
+	if found, addr, _ := hosts.HostAddressLookup("b", IPFamilyV4); !found || addr != "10.0.0.2" {
+		t.Errorf("b resolves to %q, want 10.0.0.2", addr)
+	}
+}
+
+// Given a line with more hostnames than MaxHostsPerLine
+// When Normalize reflows
+// Then it is split into consecutive lines with the same address and comment.
+func TestNormalize_Reflow(t *testing.T) {
+	raw := "10.0.0.1 h1 h2 h3 h4 h5 # owner=me\n"
+	hosts, err := NewHosts(&HostsConfig{RawText: &raw, MaxHostsPerLine: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hosts.Normalize(NormalizeOptions{Reflow: true})
+
+	want := "10.0.0.1         h1 h2 # owner=me\n10.0.0.1         h3 h4 # owner=me\n10.0.0.1         h5 # owner=me\n"
+	if got := hosts.RenderHostsFile(); got != want {
+		t.Errorf("RenderHostsFile() =\n%s\nwant:\n%s", got, want)
+	}
+	if got, _ := hosts.ListHostsBySelector("owner=me"); len(got) != 5 {
+		t.Errorf("ListHostsBySelector() = %v", got)
+	}
+}
+
+// Given runs of address lines separated by a comment
+// When Normalize sorts
+// Then each run is ordered by IP, then hostname, and the comment stays put.
+func TestNormalize_SortWithinRuns(t *testing.T) {
+	hosts := newRawHosts(t, "10.0.0.2 b\n::1 localhost\n10.0.0.10 z\n10.0.0.2 a\n# keep\n192.168.0.1 y\n10.0.0.1 x\n")
+
+	hosts.Normalize(NormalizeOptions{Sort: true})
+
+	want := strings.Join([]string{
+		"10.0.0.2         a",
+		"10.0.0.2         b",
+		"10.0.0.10        z",
+		"::1              localhost",
+		"# keep",
+		"10.0.0.1         x",
+		"192.168.0.1      y",
+	}, "\n") + "\n"
+	if got := hosts.RenderHostsFile(); got != want {
+		t.Errorf("RenderHostsFile() =\n%s\nwant:\n%s", got, want)
+	}
+}
+
+// Given a managed block and matching lines outside it
+// When Normalize runs with every option
+// Then nothing moves into or out of the block, while lines around it merge.
+func TestNormalize_BlocksAreSeparate(t *testing.T) {
+	hosts := newRawHosts(t, "10.0.0.1 a\n# BEGIN txeh:app\n10.0.0.1 b\n10.0.0.1 a\n# END txeh:app\n10.0.0.1 c\n")
+
+	hosts.Normalize(allNormalizeOptions)
+
+	want := "10.0.0.1         a c\n# BEGIN txeh:app\n10.0.0.1         b a\n# END txeh:app\n"
+	if got := hosts.RenderHostsFile(); got != want {
+		t.Errorf("RenderHostsFile() =\n%s\nwant:\n%s", got, want)
+	}
+}
+
+// Given PreserveFormatting and an oddly spaced line
+// When Normalize runs
+// Then the line is reformatted anyway.
+func TestNormalize_ReformatsPristineLines(t *testing.T) {
+	raw := "10.0.0.1\t\ta\n"
+	hosts, err := NewHosts(&HostsConfig{RawText: &raw, PreserveFormatting: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hosts.Normalize(NormalizeOptions{})
+
+	if got := hosts.RenderHostsFile(); got != "10.0.0.1         a\n" {
+		t.Errorf("RenderHostsFile() = %q", got)
+	}
+}
@@ -0,0 +1,156 @@
+package txeh
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"slices"
+	"time"
+)
+
+// ErrAddressConflict is returned under AddressPolicyReject when a hostname is
+// already mapped to a different address of the same IP family.
+var ErrAddressConflict = errors.New("hostname is mapped to another address")
+
+// AddressPolicy decides what adding a hostname does when it is already mapped
+// to a different address of the same IP family.
+type AddressPolicy int
+
+// Address policy constants for HostsConfig.AddressPolicy and AddOptions.Policy.
+const (
+	AddressPolicyDefault       AddressPolicy = iota // Use HostsConfig.AddressPolicy, or AddressPolicyMove when unset.
+	AddressPolicyMove                               // Move the hostname to the new address, except onto a loopback address. Managed blocks keep their copy.
+	AddressPolicyAllowMultiple                      // Keep the existing mapping and add the new one.
+	AddressPolicyReject                             // Leave the hosts unchanged and return ErrAddressConflict.
+)
+
+// String returns the policy name used by the CLI.
+func (p AddressPolicy) String() string {
+	switch p {
+	case AddressPolicyMove:
+		return "move"
+	case AddressPolicyAllowMultiple:
+		return "allow-multiple"
+	case AddressPolicyReject:
+		return "reject"
+	default:
+		return "default"
+	}
+}
+
+// ParseAddressPolicy parses a policy name: "move", "allow-multiple" or
+// "reject". The empty string is AddressPolicyDefault.
+func ParseAddressPolicy(s string) (AddressPolicy, error) {
+	for _, p := range []AddressPolicy{AddressPolicyMove, AddressPolicyAllowMultiple, AddressPolicyReject} {
+		if s == p.String() {
+			return p, nil
+		}
+	}
+	if s == "" {
+		return AddressPolicyDefault, nil
+	}
+	return AddressPolicyDefault, fmt.Errorf("invalid address policy %q (want move, allow-multiple or reject)", s)
+}
+
+// AddOptions configures AddHostWithOptions.
+type AddOptions struct {
+	// Comment is the inline comment of the entry.
+	Comment string
+	// Policy overrides HostsConfig.AddressPolicy for this call.
+	Policy AddressPolicy
+	// TTL, when positive, expires the entry after this long (see AddHostWithTTL).
+	TTL time.Duration
+}
+
+// AddHostWithOptions adds a host to an address. It validates like AddHostE and
+// applies opts.Policy when the host is already mapped elsewhere.
+func (h *Hosts) AddHostWithOptions(address, host string, opts AddOptions) error {
+	return h.AddHostsWithOptions(address, []string{host}, opts)
+}
+
+// AddHostsWithOptions adds hosts to an address. Nothing is added when any
+// host is invalid or, under AddressPolicyReject, already mapped to another
+// address of the same family. See AddHostWithOptions.
+func (h *Hosts) AddHostsWithOptions(address string, hosts []string, opts AddOptions) error {
+	if opts.TTL < 0 {
+		return errors.New("ttl must be positive")
+	}
+	if err := validateEntry(address, hosts); err != nil {
+		return err
+	}
+
+	comment := opts.Comment
+	if opts.TTL > 0 {
+		comment = expiringComment(comment, opts.TTL)
+	}
+
+	hosts = slices.Clone(hosts)
+	return h.mutateErr(func(x *Hosts) error {
+		policy := x.addressPolicy(opts.Policy)
+		if policy == AddressPolicyReject {
+			for _, hst := range hosts {
+				if err := x.checkAddressConflictLocked(address, hst); err != nil {
+					return err
+				}
+			}
+		}
+
+		for _, hst := range hosts {
+			if opts.TTL > 0 {
+				x.removeHostAtAddressLocked(address, hst)
+			}
+			if err := x.addHostLocked(address, hst, comment, policy); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// addressPolicy resolves AddressPolicyDefault to the configured policy.
+func (h *Hosts) addressPolicy(p AddressPolicy) AddressPolicy {
+	if p != AddressPolicyDefault {
+		return p
+	}
+	if h.HostsConfig != nil && h.AddressPolicy != AddressPolicyDefault {
+		return h.AddressPolicy
+	}
+	return AddressPolicyMove
+}
+
+// checkAddressConflictLocked returns an error wrapping ErrAddressConflict when
+// host is mapped to another address of the same family. Must be called with
+// the lock held.
+func (h *Hosts) checkAddressConflictLocked(address, host string) error {
+	addr, ok := parseAddr(address)
+	if !ok {
+		return nil
+	}
+	family := IPFamilyV4
+	if !addr.Is4() {
+		family = IPFamilyV6
+	}
+
+	host = canonicalHostname(host)
+	found, exAdd, idx := h.hostAddressLookupLocked(host, family)
+	if found && h.hostFileLines[idx].addr != addr {
+		return addressConflictError(host, exAdd)
+	}
+	return nil
+}
+
+// addressConflictError reports that host is already mapped to address.
+func addressConflictError(host, address string) error {
+	return fmt.Errorf("%s is mapped to %s: %w", host, address, ErrAddressConflict)
+}
+
+// hostOnAddressLocked reports whether host is on a line for addr.
+// Must be called with the lock held.
+func (h *Hosts) hostOnAddressLocked(host string, addr netip.Addr) bool {
+	for _, i := range h.hostLines(host, host) {
+		if h.hostFileLines[i].addr == addr {
+			return true
+		}
+	}
+	return false
+}
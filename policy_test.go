@@ -0,0 +1,151 @@
+package txeh
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+// Given a hostname mapped to one address
+// When it is added to another address of the same family under each policy
+// Then move relocates it, allow-multiple keeps both and reject changes nothing.
+func TestAddressPolicy_Modes(t *testing.T) {
+	tests := []struct {
+		policy  AddressPolicy
+		wantOld []string
+		wantNew []string
+		wantErr error
+	}{
+		{AddressPolicyDefault, nil, []string{"app"}, nil},
+		{AddressPolicyMove, nil, []string{"app"}, nil},
+		{AddressPolicyAllowMultiple, []string{"app"}, []string{"app"}, nil},
+		{AddressPolicyReject, []string{"app"}, nil, ErrAddressConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy.String(), func(t *testing.T) {
+			hosts := newRawHosts(t, "192.168.1.10 app\n")
+
+			err := hosts.AddHostWithOptions("10.8.0.5", "app", AddOptions{Policy: tt.policy})
+
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Fatalf("AddHostWithOptions() = %v, want %v", err, tt.wantErr)
+			}
+			if got := hosts.ListHostsByIP("192.168.1.10"); !slices.Equal(got, tt.wantOld) {
+				t.Errorf("old address hosts = %v, want %v", got, tt.wantOld)
+			}
+			if got := hosts.ListHostsByIP("10.8.0.5"); !slices.Equal(got, tt.wantNew) {
+				t.Errorf("new address hosts = %v, want %v", got, tt.wantNew)
+			}
+		})
+	}
+}
+
+// Given a hostname left on two addresses by allow-multiple adds
+// When it is added to a third address with the move policy
+// Then it ends up only on the new address.
+func TestAddressPolicy_Move_FromSeveralAddresses(t *testing.T) {
+	hosts := newRawHosts(t, "10.0.0.1 a b\n::1 a\n")
+	multiple := AddOptions{Policy: AddressPolicyAllowMultiple}
+	if err := hosts.AddHostWithOptions("10.0.0.2", "a", multiple); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := hosts.AddHostWithOptions("10.0.0.3", "a", AddOptions{Policy: AddressPolicyMove}); err != nil {
+		t.Fatalf("AddHostWithOptions() = %v", err)
+	}
+
+	var got []string
+	for _, pair := range hosts.ListAddressesByHost("a", true) {
+		got = append(got, pair[0])
+	}
+	if want := []string{"::1", "10.0.0.3"}; !slices.Equal(got, want) {
+		t.Errorf("addresses of a = %v, want %v", got, want)
+	}
+	want := "10.0.0.1         b\n::1              a\n10.0.0.3         a\n"
+	if got := hosts.RenderHostsFile(); got != want {
+		t.Errorf("RenderHostsFile() =\n%s\nwant:\n%s", got, want)
+	}
+}
+
+// Given HostsConfig.AddressPolicy set to allow-multiple
+// When a host is added to two addresses twice
+// Then both mappings exist once each.
+func TestAddressPolicy_ConfigAllowMultiple_NoDuplicates(t *testing.T) {
+	raw := "0.0.0.0 ads.example\n"
+	hosts, err := NewHosts(&HostsConfig{RawText: &raw, AddressPolicy: AddressPolicyAllowMultiple})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for range 2 {
+		hosts.AddHost("127.0.0.1", "ads.example")
+		hosts.AddHost("0.0.0.0", "ads.example")
+	}
+
+	want := "0.0.0.0          ads.example\n127.0.0.1        ads.example\n"
+	if got := hosts.RenderHostsFile(); got != want {
+		t.Errorf("RenderHostsFile() =\n%s\nwant:\n%s", got, want)
+	}
+}
+
+// Given HostsConfig.AddressPolicy set to reject
+// When several hosts are added and one conflicts
+// Then the call fails, nothing is added, and AddHost skips the conflicting host.
+func TestAddressPolicy_Reject_IsAtomic(t *testing.T) {
+	raw := "10.0.0.1 taken\n"
+	hosts, err := NewHosts(&HostsConfig{RawText: &raw, AddressPolicy: AddressPolicyReject})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = hosts.AddHostsWithOptions("10.0.0.2", []string{"free", "taken"}, AddOptions{})
+	if !errors.Is(err, ErrAddressConflict) {
+		t.Fatalf("AddHostsWithOptions() = %v, want ErrAddressConflict", err)
+	}
+	if got := hosts.ListHostsByIP("10.0.0.2"); len(got) != 0 {
+		t.Errorf("hosts added despite conflict: %v", got)
+	}
+
+	hosts.AddHosts("10.0.0.2", []string{"free", "taken"})
+	if got := hosts.ListHostsByIP("10.0.0.2"); !slices.Equal(got, []string{"free"}) {
+		t.Errorf("AddHosts under reject = %v, want [free]", got)
+	}
+
+	if err := hosts.AddHostWithOptions("10.0.0.2", "taken", AddOptions{Policy: AddressPolicyMove}); err != nil {
+		t.Errorf("per-call move override = %v", err)
+	}
+}
+
+// Given options with a comment and TTL
+// When a host is added
+// Then the comment carries the expires tag.
+func TestAddHostWithOptions_CommentAndTTL(t *testing.T) {
+	stubTTLClock(t, time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC))
+	hosts := newRawHosts(t, "")
+
+	if err := hosts.AddHostWithOptions("10.0.0.1", "tmp", AddOptions{Comment: "dev", TTL: time.Hour}); err != nil {
+		t.Fatal(err)
+	}
+	if got := hosts.ListHostsByComment("dev expires=2026-10-16T13:00:00Z"); len(got) != 1 {
+		t.Errorf("entry with expires tag not found:\n%s", hosts.RenderHostsFile())
+	}
+	if err := hosts.AddHostWithOptions("10.0.0.1", "tmp", AddOptions{TTL: -1}); err == nil {
+		t.Error("negative TTL accepted")
+	}
+}
+
+func TestParseAddressPolicy(t *testing.T) {
+	for _, p := range []AddressPolicy{AddressPolicyDefault, AddressPolicyMove, AddressPolicyAllowMultiple, AddressPolicyReject} {
+		name := p.String()
+		if p == AddressPolicyDefault {
+			name = ""
+		}
+		if got, err := ParseAddressPolicy(name); err != nil || got != p {
+			t.Errorf("ParseAddressPolicy(%q) = %v, %v", name, got, err)
+		}
+	}
+	if _, err := ParseAddressPolicy("merge"); err == nil {
+		t.Error("ParseAddressPolicy(merge) succeeded")
+	}
+}
@@ -0,0 +1,79 @@
+package txeh
+
+import (
+	"strings"
+	"testing"
+)
+
+const testHandAlignedHosts = `# hand-maintained
+127.0.0.1	localhost   MyHost.Local
+10.0.0.1        db   DB-Replica    # database
+10.0.0.2  cache
+`
+
+// Given a hand-aligned file and PreserveFormatting
+// When it is rendered without changes, or after a no-op add
+// Then the output is byte for byte identical to the input.
+func TestPreserveFormatting_NoChange_ZeroDiff(t *testing.T) {
+	path := writeHostsFile(t, testHandAlignedHosts)
+
+	hosts, err := NewHosts(&HostsConfig{ReadFilePath: path, PreserveFormatting: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := hosts.RenderHostsFile(); got != testHandAlignedHosts {
+		t.Errorf("unchanged render differs:\n%q\nwant:\n%q", got, testHandAlignedHosts)
+	}
+
+	hosts.AddHost("10.0.0.1", "db")
+	hosts.AddHost("10.0.0.1", "DB-REPLICA")
+	if err := hosts.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if got := readHostsFile(t, path); got != testHandAlignedHosts {
+		t.Errorf("no-op add changed the file:\n%q", got)
+	}
+}
+
+// Given PreserveFormatting
+// When one line is changed and a new line is added
+// Then only those lines are reformatted.
+func TestPreserveFormatting_OnlyTouchedLinesReformatted(t *testing.T) {
+	raw := testHandAlignedHosts
+	hosts, err := NewHosts(&HostsConfig{RawText: &raw, PreserveFormatting: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hosts.AddHost("10.0.0.2", "cache-2")
+	hosts.AddHost("10.0.0.3", "queue")
+
+	want := `# hand-maintained
+127.0.0.1	localhost   MyHost.Local
+10.0.0.1        db   DB-Replica    # database
+10.0.0.2         cache cache-2
+10.0.0.3         queue
+`
+	if got := hosts.RenderHostsFile(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	hosts.RemoveHost("db-replica")
+	if got := hosts.RenderHostsFile(); !strings.Contains(got, "10.0.0.1         db # database\n") {
+		t.Errorf("removal did not reformat the changed line:\n%s", got)
+	}
+	if !strings.Contains(hosts.RenderHostsFile(), "127.0.0.1\tlocalhost   MyHost.Local\n") {
+		t.Error("untouched line was reformatted")
+	}
+}
+
+// Given PreserveFormatting is off (the default)
+// When a hand-aligned file is rendered
+// Then address lines are normalized as before.
+func TestPreserveFormatting_Off_Normalizes(t *testing.T) {
+	hosts := newRawHosts(t, testHandAlignedHosts)
+
+	if got := hosts.RenderHostsFile(); !strings.Contains(got, "127.0.0.1        localhost myhost.local\n") {
+		t.Errorf("expected normalized line:\n%s", got)
+	}
+}
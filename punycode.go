@@ -0,0 +1,68 @@
+package txeh
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/idna"
+)
+
+// acePrefix marks a punycode-encoded hostname label (RFC 3490).
+const acePrefix = "xn--"
+
+// isASCII reports whether s contains only ASCII characters.
+func isASCII(s string) bool {
+	for i := range len(s) {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// hostnameToASCII converts every non-ASCII label of hostname to its punycode
+// form with the xn-- prefix, using the IDNA lookup profile: labels are
+// mapped and NFC-normalized per UTS #46 first, so "CAFÉ" and "café"
+// both become "xn--caf-dma". ASCII labels are returned unchanged, which keeps
+// '_' (not allowed by IDNA) usable for service records.
+func hostnameToASCII(hostname string) (string, error) {
+	if isASCII(hostname) {
+		return hostname, nil
+	}
+
+	labels := strings.Split(hostname, ".")
+	for i, l := range labels {
+		if isASCII(l) {
+			continue
+		}
+		enc, err := idna.Lookup.ToASCII(l)
+		if err != nil {
+			return "", err
+		}
+		labels[i] = enc
+	}
+
+	return strings.Join(labels, "."), nil
+}
+
+// HostnameToUnicode converts the xn-- labels of hostname back to Unicode,
+// e.g. for display. Labels that are not valid punycode produce an error.
+func HostnameToUnicode(hostname string) (string, error) {
+	labels := strings.Split(hostname, ".")
+	for i, l := range labels {
+		if !hasACEPrefix(l) {
+			continue
+		}
+		dec, err := idna.Lookup.ToUnicode(l)
+		if err != nil {
+			return "", &ValidationError{Value: hostname, Err: ErrInvalidHostname}
+		}
+		labels[i] = dec
+	}
+	return strings.Join(labels, "."), nil
+}
+
+// hasACEPrefix reports whether label starts with a case-insensitive xn--.
+func hasACEPrefix(label string) bool {
+	return len(label) >= len(acePrefix) && strings.EqualFold(label[:len(acePrefix)], acePrefix)
+}
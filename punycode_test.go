@@ -0,0 +1,168 @@
+package txeh
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestHostnameToASCII_RoundTrip(t *testing.T) {
+	// Sample strings from RFC 3492 section 7.1 that are valid IDNA labels,
+	// lowercased as the lookup mapping does, and common IDNs.
+	tests := []struct {
+		unicode string
+		encoded string
+	}{
+		{"bücher", "xn--bcher-kva"},
+		{"café", "xn--caf-dma"},
+		{"münchen", "xn--mnchen-3ya"},
+		{"例え", "xn--r8jz45g"},
+		{"他们为什么不说中文", "xn--ihqwcrb4cv8a8dqg056pqjye"},
+		{"他們爲什麽不說中文", "xn--ihqwctvzc91f659drss3x8bo0yb"},
+		{"pročprostěnemluvíčesky", "xn--proprostnemluvesky-uyb24dma41a"},
+		{"למההםפשוטלאמדבריםעברית", "xn--4dbcagdahymbxekheh6e0a7fei0b"},
+		{"なぜみんな日本語を話してくれないのか", "xn--n8jok5ay5dzabd5bym9f0cm5685rrjetr6pdxa"},
+		{"почемужеонинеговорятпорусски", "xn--b1abfaaepdrnnbgefbadotcwatmq2g4l"},
+		{"porquénopuedensimplementehablarenespañol", "xn--porqunopuedensimplementehablarenespaol-fmd56a"},
+		{"tạisaohọkhôngthểchỉnóitiếngviệt", "xn--tisaohkhngthchnitingvit-kjcr8268qyxafd2f1b9g"},
+		{"3年b組金八先生", "xn--3b-ww4c5e180e575a65lsy2b"},
+		{"安室奈美恵-with-super-monkeys", "xn---with-super-monkeys-pc58ag80a8qai00g7n9n"},
+		{"パフィーdeルンバ", "xn--de-jg4avhby1noc0d"},
+		{"そのスピードで", "xn--d9juau41awczczp"},
+	}
+	for _, tt := range tests {
+		got, err := hostnameToASCII(tt.unicode)
+		if err != nil || got != tt.encoded {
+			t.Errorf("hostnameToASCII(%q) = %q, %v, want %q", tt.unicode, got, err, tt.encoded)
+		}
+		back, err := HostnameToUnicode(tt.encoded)
+		if err != nil || back != tt.unicode {
+			t.Errorf("HostnameToUnicode(%q) = %q, %v, want %q", tt.encoded, back, err, tt.unicode)
+		}
+	}
+}
+
+// Given the same name in different Unicode forms
+// When it is converted to ASCII
+// Then NFC normalization and the UTS #46 mapping give one punycode form.
+func TestHostnameToASCII_NormalizesUnicode(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"cafe\u0301.local", "xn--caf-dma.local"}, // e + combining acute accent
+		{"CAFÉ.local", "xn--caf-dma.local"},
+		{"ｂüｃｈｅｒ.local", "xn--bcher-kva.local"}, // fullwidth letters
+		{"bücher。local", "xn--bcher-kva.local"}, // ideographic full stop
+	}
+	for _, tt := range tests {
+		got, err := hostnameToASCII(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("hostnameToASCII(%q) = %q, %v, want %q", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestNormalizeHostname(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"Example.COM", "example.com"},
+		{" café.local ", "xn--caf-dma.local"},
+		{"CAFÉ.local", "xn--caf-dma.local"},
+		{"bücher.例え.test", "xn--bcher-kva.xn--r8jz45g.test"},
+		{"xn--caf-dma.local", "xn--caf-dma.local"},
+	}
+	for _, tt := range tests {
+		got, err := NormalizeHostname(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("NormalizeHostname(%q) = %q, %v, want %q", tt.in, got, err, tt.want)
+		}
+	}
+
+	if _, err := NormalizeHostname("bad name"); !errors.Is(err, ErrInvalidHostname) {
+		t.Errorf("NormalizeHostname(bad name) = %v, want ErrInvalidHostname", err)
+	}
+}
+
+func TestHostnameToUnicode(t *testing.T) {
+	got, err := HostnameToUnicode("xn--bcher-kva.example.com")
+	if err != nil || got != "bücher.example.com" {
+		t.Errorf("HostnameToUnicode = %q, %v", got, err)
+	}
+	if _, err := HostnameToUnicode("xn--!!.example.com"); !errors.Is(err, ErrInvalidHostname) {
+		t.Errorf("HostnameToUnicode(invalid) = %v, want ErrInvalidHostname", err)
+	}
+}
+
+func TestValidateHostname_RFC1123(t *testing.T) {
+	tests := []struct {
+		hostname string
+		want     error
+	}{
+		{"café.local", nil},
+		{"xn--caf-dma.local", nil},
+		{strings.Repeat("a", 63) + ".example.com", nil},
+		{strings.Repeat("a", 64) + ".example.com", ErrLabelTooLong},
+		{strings.Repeat("é", 60) + ".local", ErrLabelTooLong},
+		{"-leading.example.com", ErrInvalidHostname},
+		{"trailing-.example.com", ErrInvalidHostname},
+		{"xn--!!.local", ErrInvalidHostname},
+		{"xn--a-99999999999.local", ErrInvalidHostname},
+		{"café local", ErrInvalidHostname},
+	}
+	for _, tt := range tests {
+		err := ValidateHostname(tt.hostname)
+		if !errors.Is(err, tt.want) || (tt.want == nil && err != nil) {
+			t.Errorf("ValidateHostname(%q) = %v, want %v", tt.hostname, err, tt.want)
+		}
+	}
+}
+
+// Given an internationalized hostname added in Unicode form
+// When it is looked up or removed using either form
+// Then the stored punycode entry is found.
+func TestAddHost_UnicodeHostname_LookupBothForms(t *testing.T) {
+	hosts := newRawHosts(t, testHostsLocalhost)
+
+	hosts.AddHost(testIPv4Alt, "Café.local")
+
+	if !strings.Contains(hosts.RenderHostsFile(), "xn--caf-dma.local") {
+		t.Fatalf("rendered file missing punycode name:\n%s", hosts.RenderHostsFile())
+	}
+
+	for _, name := range []string{"café.local", "xn--caf-dma.local"} {
+		got := hosts.ListAddressesByHost(name, true)
+		if len(got) != 1 || got[0][0] != testIPv4Alt || got[0][1] != "xn--caf-dma.local" {
+			t.Errorf("ListAddressesByHost(%q) = %v", name, got)
+		}
+		if found, addr, _ := hosts.HostAddressLookup(name, IPFamilyV4); !found || addr != testIPv4Alt {
+			t.Errorf("HostAddressLookup(%q) = %v, %q", name, found, addr)
+		}
+	}
+
+	if got := hosts.ListAddressesByHost("café", false); len(got) != 1 {
+		t.Errorf("ListAddressesByHost(café, partial) = %v", got)
+	}
+
+	hosts.RemoveHost("CAFÉ.local")
+	if got := hosts.ListAddressesByHost("xn--caf-dma.local", true); len(got) != 0 {
+		t.Errorf("after RemoveHost: %v", got)
+	}
+}
+
+// Given a hosts file that already holds a raw Unicode hostname
+// When it is looked up in Unicode form
+// Then the raw entry still matches.
+func TestHostAddressLookup_RawUnicodeEntry(t *testing.T) {
+	hosts := newRawHosts(t, "127.0.0.1 café.local\n")
+
+	if found, _, _ := hosts.HostAddressLookup("café.local", IPFamilyV4); !found {
+		t.Error("raw Unicode entry not found")
+	}
+	hosts.RemoveHost("café.local")
+	if got := hosts.ListHostsByIP(testIPv4Localhost); len(got) != 0 {
+		t.Errorf("after RemoveHost: %v", got)
+	}
+}
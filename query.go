@@ -0,0 +1,188 @@
+package txeh
+
+import (
+	"iter"
+	"net/netip"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Entry is one hostname mapping of an address line.
+type Entry struct {
+	Addr     netip.Addr
+	Hostname string
+	Comment  string
+	// Line is the 1-based line number in the rendered file.
+	Line int
+	// Tags are the key=value tags of the inline comment (see ParseTags).
+	// The map is shared by the entries of a line and with the Hosts; treat
+	// it as read-only and clone it to keep a modified copy.
+	Tags map[string]string
+}
+
+// Query selects entries. Build one from the By functions and combine them
+// with And, Or and Not:
+//
+//	q := txeh.ByHostnameSuffix("local").And(txeh.ByFamily(txeh.IPFamilyV4))
+type Query func(Entry) bool
+
+// And matches entries that match q and every one of others.
+func (q Query) And(others ...Query) Query {
+	return func(e Entry) bool {
+		if !q(e) {
+			return false
+		}
+		for _, o := range others {
+			if !o(e) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// Or matches entries that match q or any one of others.
+func (q Query) Or(others ...Query) Query {
+	return func(e Entry) bool {
+		if q(e) {
+			return true
+		}
+		for _, o := range others {
+			if o(e) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Not matches entries that q does not match.
+func (q Query) Not() Query {
+	return func(e Entry) bool { return !q(e) }
+}
+
+// ByPrefix matches entries whose address is inside prefix, e.g. the result
+// of netip.ParsePrefix("10.0.0.0/8").
+func ByPrefix(prefix netip.Prefix) Query {
+	return func(e Entry) bool { return prefixContains(prefix, e.Addr) }
+}
+
+// ByFamily matches IPv4 or IPv6 entries.
+func ByFamily(family IPFamily) Query {
+	return func(e Entry) bool { return e.Addr.Is4() == (family == IPFamilyV4) }
+}
+
+// ByHostname matches a hostname exactly. Internationalized names also match
+// their punycode form.
+func ByHostname(hostname string) Query {
+	canonical, lower := hostnameForms(hostname)
+	return func(e Entry) bool { return e.Hostname == canonical || e.Hostname == lower }
+}
+
+// ByHostnameGlob matches hostnames against a path.Match pattern such as
+// "*.local". A malformed pattern matches nothing.
+func ByHostnameGlob(pattern string) Query {
+	pattern = strings.ToLower(pattern)
+	return func(e Entry) bool {
+		ok, err := path.Match(pattern, e.Hostname)
+		return err == nil && ok
+	}
+}
+
+// ByHostnameRegexp matches hostnames against re.
+func ByHostnameRegexp(re *regexp.Regexp) Query {
+	return func(e Entry) bool { return re.MatchString(e.Hostname) }
+}
+
+// ByHostnameSuffix matches a domain and its subdomains: "example.com"
+// matches "example.com" and "api.example.com" but not "badexample.com".
+func ByHostnameSuffix(domain string) Query {
+	domain = canonicalHostname(strings.TrimPrefix(domain, "."))
+	return func(e Entry) bool {
+		return e.Hostname == domain || strings.HasSuffix(e.Hostname, "."+domain)
+	}
+}
+
+// ByComment matches entries whose inline comment equals comment.
+func ByComment(comment string) Query {
+	comment = strings.TrimSpace(comment)
+	return func(e Entry) bool { return e.Comment == comment }
+}
+
+// ByTag matches entries tagged key=value.
+func ByTag(key, value string) Query {
+	return func(e Entry) bool {
+		v, ok := e.Tags[key]
+		return ok && v == value
+	}
+}
+
+// BySelector matches entries whose tags satisfy sel (see ParseSelector).
+func BySelector(sel Selector) Query {
+	return func(e Entry) bool { return sel.Matches(e.Tags) }
+}
+
+// entriesBatch is the number of lines Entries reads per lock acquisition.
+const entriesBatch = 256
+
+// Entries returns every hostname mapping in file order. Lines whose address
+// does not parse are skipped. The lock is only held while a batch of lines
+// is read, so the loop body may call other methods of h; changes made while
+// iterating may or may not be seen.
+func (h *Hosts) Entries() iter.Seq[Entry] {
+	return func(yield func(Entry) bool) {
+		var buf []Entry
+		for i := 0; ; i += entriesBatch {
+			var ok bool
+			if buf, ok = h.lineEntries(i, buf[:0]); !ok {
+				return
+			}
+			for _, e := range buf {
+				if !yield(e) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// Query returns the entries matching q in file order. See Entries.
+func (h *Hosts) Query(q Query) iter.Seq[Entry] {
+	return func(yield func(Entry) bool) {
+		for e := range h.Entries() {
+			if q(e) && !yield(e) {
+				return
+			}
+		}
+	}
+}
+
+// lineEntries appends the entries of the entriesBatch lines starting at
+// line start to buf. It reports false when start is past the end of the file.
+func (h *Hosts) lineEntries(start int, buf []Entry) ([]Entry, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if start >= len(h.hostFileLines) {
+		return buf, false
+	}
+
+	for i := start; i < min(start+entriesBatch, len(h.hostFileLines)); i++ {
+		hfl := &h.hostFileLines[i]
+		if hfl.LineType != ADDRESS || !hfl.addr.IsValid() {
+			continue
+		}
+		for _, hn := range hfl.Hostnames {
+			buf = append(buf, Entry{
+				Addr:     hfl.addr,
+				Hostname: hn,
+				Comment:  hfl.Comment,
+				Line:     i + 1,
+				Tags:     hfl.Tags,
+			})
+		}
+	}
+
+	return buf, true
+}
@@ -0,0 +1,133 @@
+package txeh
+
+import (
+	"fmt"
+	"net/netip"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+)
+
+const queryHosts = `127.0.0.1 localhost
+# dev
+10.0.0.1 api.example.com web.example.com # owner=me env=dev
+10.1.0.1 db.internal # owner=ops
+fe80::1%eth0 link.local
+::1 localhost
+bad-address orphan
+`
+
+// queryHostnames collects the hostnames of the entries matching q.
+func queryHostnames(h *Hosts, q Query) []string {
+	var names []string
+	for e := range h.Query(q) {
+		names = append(names, e.Hostname)
+	}
+	return names
+}
+
+// Given a hosts file
+// When Entries is ranged over
+// Then every mapping of a parseable address line is yielded with its line and tags.
+func TestEntries(t *testing.T) {
+	hosts := newRawHosts(t, queryHosts)
+
+	var got []Entry
+	for e := range hosts.Entries() {
+		got = append(got, e)
+	}
+
+	if len(got) != 6 {
+		t.Fatalf("Entries() yielded %d entries: %v", len(got), got)
+	}
+	web := got[2]
+	if web.Hostname != "web.example.com" || web.Line != 3 || web.Addr != netip.MustParseAddr("10.0.0.1") ||
+		web.Comment != "owner=me env=dev" || web.Tags["env"] != "dev" {
+		t.Errorf("entry 2 = %+v", web)
+	}
+	if got[4].Addr.Zone() != "eth0" {
+		t.Errorf("zone lost: %v", got[4].Addr)
+	}
+}
+
+func TestQuery_Filters(t *testing.T) {
+	hosts := newRawHosts(t, queryHosts)
+	sel, err := ParseSelector("owner=me")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		query Query
+		want  []string
+	}{
+		{"prefix", ByPrefix(netip.MustParsePrefix("10.0.0.0/16")), []string{"api.example.com", "web.example.com"}},
+		{"family v6", ByFamily(IPFamilyV6), []string{"link.local", "localhost"}},
+		{"hostname", ByHostname("DB.internal"), []string{"db.internal"}},
+		{"glob", ByHostnameGlob("*.example.com"), []string{"api.example.com", "web.example.com"}},
+		{"bad glob", ByHostnameGlob("[a"), nil},
+		{"regexp", ByHostnameRegexp(regexp.MustCompile(`^(api|db)\.`)), []string{"api.example.com", "db.internal"}},
+		{"suffix", ByHostnameSuffix(".example.com"), []string{"api.example.com", "web.example.com"}},
+		{"suffix is not substring", ByHostnameSuffix("ample.com"), nil},
+		{"comment", ByComment("owner=ops"), []string{"db.internal"}},
+		{"tag", ByTag("owner", "ops"), []string{"db.internal"}},
+		{"selector", BySelector(sel), []string{"api.example.com", "web.example.com"}},
+		{"and", ByFamily(IPFamilyV4).And(ByHostname("localhost")), []string{"localhost"}},
+		{"or", ByComment("owner=ops").Or(ByHostname("link.local")), []string{"db.internal", "link.local"}},
+		{"not", ByHostnameSuffix("example.com").Not().And(ByFamily(IPFamilyV4)), []string{"localhost", "db.internal"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := queryHostnames(hosts, tt.query); !slices.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// Given a query loop that stops early and mutates the hosts
+// When the iteration runs
+// Then it neither deadlocks nor yields after break.
+func TestQuery_BreakAndMutateInLoop(t *testing.T) {
+	hosts := newRawHosts(t, queryHosts)
+
+	n := 0
+	for e := range hosts.Query(ByFamily(IPFamilyV4)) {
+		hosts.RemoveHost(e.Hostname)
+		n++
+		if n == 2 {
+			break
+		}
+	}
+
+	if n != 2 {
+		t.Errorf("yielded %d entries before break", n)
+	}
+	if got := hosts.ListHostsByIP("127.0.0.1"); len(got) != 0 {
+		t.Errorf("localhost not removed: %v", got)
+	}
+}
+
+// Given a large file of tagged lines
+// When Entries is ranged over
+// Then the allocations don't grow with the number of entries.
+func TestEntries_AllocationsDoNotScale(t *testing.T) {
+	var sb strings.Builder
+	for i := range 10_000 {
+		fmt.Fprintf(&sb, "10.0.%d.%d a-%d b-%d c-%d # owner=kubefwd env=dev\n", i/250, i%250+1, i, i, i)
+	}
+	hosts := newRawHosts(t, sb.String())
+
+	allocs := testing.AllocsPerRun(5, func() {
+		for e := range hosts.Entries() {
+			if e.Tags["owner"] != "kubefwd" {
+				t.Fatalf("entry %+v", e)
+			}
+		}
+	})
+	if allocs > 100 {
+		t.Errorf("Entries allocated %.0f times for 30000 entries", allocs)
+	}
+}
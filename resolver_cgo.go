@@ -0,0 +1,7 @@
+//go:build cgo && !netgo
+
+package txeh
+
+// cgoResolver reports whether the net package can resolve through the
+// system's C library.
+const cgoResolver = true
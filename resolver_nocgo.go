@@ -0,0 +1,8 @@
+//go:build !cgo || netgo
+
+package txeh
+
+// cgoResolver reports whether the net package can resolve through the
+// system's C library. Without cgo, or with the netgo tag, Go's own resolver
+// is used on Linux and the BSDs.
+const cgoResolver = false
@@ -0,0 +1,178 @@
+package txeh
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+)
+
+// ErrReadOnlyStore is returned when saving to a Store that cannot be written,
+// such as an FSStore.
+var ErrReadOnlyStore = errors.New("store is read-only")
+
+// Store is a backend holding the content of a hosts file. Set
+// HostsConfig.Store to load and save hosts somewhere other than the OS
+// filesystem, e.g. a MemoryStore in tests or an FSStore over an embedded
+// filesystem. Load and Store must be safe for concurrent use.
+type Store interface {
+	// Load returns the current content.
+	Load() ([]byte, error)
+	// Store replaces the content with data.
+	Store(data []byte) error
+}
+
+// FileStore is a hosts file in the OS filesystem. A Hosts using it behaves
+// exactly like one configured with ReadFilePath and WriteFilePath: saves are
+// atomic (see Hosts.SaveAs) and locking, backups and the concurrent
+// modification check apply.
+type FileStore struct {
+	// Path is the file Load reads.
+	Path string
+	// WritePath is the file Store writes. Empty means Path.
+	WritePath string
+}
+
+// NewFileStore returns a FileStore reading and writing path.
+func NewFileStore(path string) *FileStore {
+	return &FileStore{Path: path}
+}
+
+// Load reads the file.
+func (s *FileStore) Load() ([]byte, error) {
+	data, err := os.ReadFile(filepath.Clean(s.Path))
+	if err != nil {
+		return nil, fmt.Errorf("read hosts file %s: %w", s.Path, err)
+	}
+	return data, nil
+}
+
+// Store atomically replaces the file with data.
+func (s *FileStore) Store(data []byte) error {
+	if err := writeFileAtomic(s.writePath(), data); err != nil {
+		return fmt.Errorf("write hosts file %s: %w", s.writePath(), err)
+	}
+	return nil
+}
+
+// writePath returns the file Store writes.
+func (s *FileStore) writePath() string {
+	if s.WritePath != "" {
+		return s.WritePath
+	}
+	return s.Path
+}
+
+// MemoryStore keeps the hosts file in memory. Unlike RawText, a Hosts using
+// it can Save and Reload, which makes it a stand-in for the real hosts file
+// in tests. The zero value is an empty file.
+type MemoryStore struct {
+	mu   sync.Mutex
+	data []byte
+}
+
+// NewMemoryStore returns a MemoryStore holding content.
+func NewMemoryStore(content string) *MemoryStore {
+	return &MemoryStore{data: []byte(content)}
+}
+
+// Load returns a copy of the content.
+func (s *MemoryStore) Load() ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return slices.Clone(s.data), nil
+}
+
+// Store replaces the content with a copy of data.
+func (s *MemoryStore) Store(data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.data = slices.Clone(data)
+	return nil
+}
+
+// String returns the content.
+func (s *MemoryStore) String() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return string(s.data)
+}
+
+// FSStore reads the hosts file from an fs.FS, such as an embed.FS or
+// os.DirFS. It is read-only: Store returns an error wrapping
+// ErrReadOnlyStore.
+type FSStore struct {
+	FS fs.FS
+	// Path is the file in FS, in fs.ValidPath form ("etc/hosts").
+	Path string
+}
+
+// NewFSStore returns an FSStore reading path from fsys.
+func NewFSStore(fsys fs.FS, path string) *FSStore {
+	return &FSStore{FS: fsys, Path: path}
+}
+
+// Load reads the file.
+func (s *FSStore) Load() ([]byte, error) {
+	data, err := fs.ReadFile(s.FS, s.Path)
+	if err != nil {
+		return nil, fmt.Errorf("read hosts file %s: %w", s.Path, err)
+	}
+	return data, nil
+}
+
+// Store returns an error wrapping ErrReadOnlyStore.
+func (s *FSStore) Store([]byte) error {
+	return fmt.Errorf("write hosts file %s: %w", s.Path, ErrReadOnlyStore)
+}
+
+// customStore returns the configured Store when it is not a FileStore, or
+// nil when the hosts live in the OS filesystem (or in memory, see
+// inMemorySource).
+func (hc *HostsConfig) customStore() Store {
+	if hc == nil || hc.Store == nil || hc.inMemorySource() != "" {
+		return nil
+	}
+	if _, ok := hc.Store.(*FileStore); ok {
+		return nil
+	}
+	return hc.Store
+}
+
+// nonFileSource names the config field the hosts were loaded from when it is
+// not a file, or returns "".
+func (hc *HostsConfig) nonFileSource() string {
+	if src := hc.inMemorySource(); src != "" {
+		return src
+	}
+	if hc.customStore() != nil {
+		return "Store"
+	}
+	return ""
+}
+
+// backendLocked returns the Store the hosts are loaded from.
+func (h *Hosts) backendLocked() Store {
+	if s := h.customStore(); s != nil {
+		return s
+	}
+	return &FileStore{Path: h.ReadFilePath, WritePath: h.WriteFilePath}
+}
+
+// saveToStoreLocked renders the hosts file into s and runs the configured
+// auto flush. Must be called with the lock held.
+func (h *Hosts) saveToStoreLocked(s Store) error {
+	if err := s.Store([]byte(h.renderLocked())); err != nil {
+		return err
+	}
+	h.pending = nil
+	h.coalescedWrittenLocked()
+
+	return h.autoFlushLocked()
+}
@@ -0,0 +1,202 @@
+package txeh
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestMemoryStore_SaveAndReload(t *testing.T) {
+	t.Parallel()
+
+	// Given: hosts loaded from a memory store
+	store := NewMemoryStore(testHostsLocalhost)
+	h, err := NewHosts(&HostsConfig{Store: store})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// When: a host is added and saved
+	h.AddHost(testIPv4Alt, "app.local")
+	if err := h.Save(); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	// Then: the store holds the rendered file
+	if got, want := store.String(), h.RenderHostsFile(); got != want {
+		t.Errorf("store = %q, want %q", got, want)
+	}
+
+	// When: the store changes and the hosts are reloaded
+	if err := store.Store([]byte("10.0.0.1 other.local\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Reload(); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+
+	// Then: the new content is loaded
+	if got := h.ListHostsByIP("10.0.0.1"); len(got) != 1 || got[0] != "other.local" {
+		t.Errorf("ListHostsByIP() = %v, want [other.local]", got)
+	}
+}
+
+func TestMemoryStore_SaveMergeKeepsOtherChanges(t *testing.T) {
+	t.Parallel()
+
+	// Given: hosts loaded from a memory store that another writer then changes
+	store := NewMemoryStore(testHostsLocalhost)
+	h, err := NewHosts(&HostsConfig{Store: store})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.AddHost(testIPv4Alt, "mine.local")
+	if err := store.Store([]byte(testHostsLocalhost + "10.0.0.1 theirs.local\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	// When: the change is merged
+	if err := h.SaveMerge(); err != nil {
+		t.Fatalf("SaveMerge() error = %v", err)
+	}
+
+	// Then: both entries are stored
+	got := store.String()
+	if !strings.Contains(got, "mine.local") || !strings.Contains(got, "theirs.local") {
+		t.Errorf("store = %q, want both entries", got)
+	}
+}
+
+func TestMemoryStore_UpdateSaves(t *testing.T) {
+	t.Parallel()
+
+	// Given: hosts loaded from a memory store
+	store := &MemoryStore{}
+	h, err := NewHosts(&HostsConfig{Store: store})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// When: a transaction commits
+	err = h.Update(func(tx *Tx) error {
+		tx.AddHost(testIPv4Localhost, "localhost")
+		return nil
+	})
+
+	// Then: the store is written
+	if err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if got := store.String(); !strings.Contains(got, "localhost") {
+		t.Errorf("store = %q, want localhost", got)
+	}
+}
+
+func TestMemoryStore_FileOnlyOperationsFail(t *testing.T) {
+	t.Parallel()
+
+	// Given: hosts loaded from a memory store
+	h, err := NewHosts(&HostsConfig{Store: NewMemoryStore(""), LockTimeout: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// When/Then: file operations name the store
+	if err := h.AcquireLock(0); err == nil || !strings.Contains(err.Error(), "Store") {
+		t.Errorf("AcquireLock() error = %v, want an error naming Store", err)
+	}
+	if _, err := h.ListBackups(); err == nil {
+		t.Error("ListBackups() error = nil, want an error")
+	}
+}
+
+func TestFSStore_LoadsAndRejectsSave(t *testing.T) {
+	t.Parallel()
+
+	// Given: a hosts file in an fs.FS
+	fsys := fstest.MapFS{"etc/hosts": {Data: []byte(testHostsLocalhost)}}
+
+	// When: hosts are loaded from it and saved
+	h, err := NewHosts(&HostsConfig{Store: NewFSStore(fsys, "etc/hosts")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = h.Save()
+
+	// Then: loading works and saving reports a read-only store
+	if got := h.ListHostsByIP(testIPv4Localhost); len(got) != 1 || got[0] != "localhost" {
+		t.Errorf("ListHostsByIP() = %v, want [localhost]", got)
+	}
+	if !errors.Is(err, ErrReadOnlyStore) {
+		t.Errorf("Save() error = %v, want ErrReadOnlyStore", err)
+	}
+}
+
+func TestFSStore_MissingFile(t *testing.T) {
+	t.Parallel()
+
+	// Given: an empty fs.FS
+	// When: hosts are loaded from it
+	_, err := NewHosts(&HostsConfig{Store: NewFSStore(fstest.MapFS{}, "hosts")})
+
+	// Then: the error wraps fs.ErrNotExist
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("NewHosts() error = %v, want fs.ErrNotExist", err)
+	}
+}
+
+func TestFileStore_BehavesLikePaths(t *testing.T) {
+	t.Parallel()
+
+	// Given: hosts loaded through a FileStore
+	path := writeHostsFile(t, testHostsLocalhost)
+	h, err := NewHosts(&HostsConfig{Store: NewFileStore(path)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// When: the file changes on disk and a save is attempted
+	if err := os.WriteFile(path, []byte(testHostsLocalhost+"10.0.0.1 other.local\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	h.AddHost(testIPv4Alt, "app.local")
+	err = h.Save()
+
+	// Then: the concurrent modification check applies
+	if h.WriteFilePath != path {
+		t.Errorf("WriteFilePath = %q, want %q", h.WriteFilePath, path)
+	}
+	if !errors.Is(err, ErrConcurrentModification) {
+		t.Errorf("Save() error = %v, want ErrConcurrentModification", err)
+	}
+}
+
+func TestFileStore_LoadAndStore(t *testing.T) {
+	t.Parallel()
+
+	// Given: a FileStore writing to another file
+	src := writeHostsFile(t, testHostsLocalhost)
+	dst := filepath.Join(t.TempDir(), "hosts")
+	s := &FileStore{Path: src, WritePath: dst}
+
+	// When: content is loaded and stored
+	data, err := s.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Store(append(data, "10.0.0.1 app.local\n"...)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Then: the write path holds the new content and the source is untouched
+	if got := readHostsFile(t, dst); got != testHostsLocalhost+"10.0.0.1 app.local\n" {
+		t.Errorf("write path = %q", got)
+	}
+	if got := readHostsFile(t, src); got != testHostsLocalhost {
+		t.Errorf("read path = %q", got)
+	}
+}
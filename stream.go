@@ -0,0 +1,105 @@
+package txeh
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ParseHostsReader parses hosts file content read from r line by line, so
+// stdin, sockets and decompressing readers can be parsed without staging the
+// whole input. A UTF-8 BOM is dropped and CRLF line endings are accepted.
+func ParseHostsReader(r io.Reader) ([]HostFileLine, error) {
+	hostFileLines, _, err := readHosts(r)
+	if err != nil {
+		return nil, fmt.Errorf("read hosts: %w", err)
+	}
+	return hostFileLines, nil
+}
+
+// readHosts parses r line by line and records its format: CRLF is chosen
+// when most line breaks are CRLF, and empty input is treated as LF with a
+// final newline.
+func readHosts(r io.Reader) (HostFileLines, fileFormat, error) {
+	br := bufio.NewReader(r)
+	hostFileLines := HostFileLines{}
+	format := fileFormat{finalNewline: true}
+	crlf, lf := 0, 0
+
+	for first := true; ; first = false {
+		s, err := br.ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, fileFormat{}, err
+		}
+		if first {
+			s, format.bom = strings.CutPrefix(s, utf8BOM)
+		}
+
+		if s != "" {
+			line, terminated := strings.CutSuffix(s, "\n")
+			format.finalNewline = terminated
+			if terminated {
+				var cr bool
+				if line, cr = strings.CutSuffix(line, "\r"); cr {
+					crlf++
+				} else {
+					lf++
+				}
+			}
+			hostFileLines = append(hostFileLines, parseHostsLine(len(hostFileLines), line))
+		}
+
+		if err != nil {
+			break
+		}
+	}
+	format.crlf = crlf > lf
+
+	return hostFileLines, format, nil
+}
+
+// WriteTo writes the rendered hosts file to w one line at a time through a
+// buffer, implementing io.WriterTo. The output is the same as RenderHostsFile.
+func (h *Hosts) WriteTo(w io.Writer) (int64, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	cw := &countingWriter{w: w}
+	bw := bufio.NewWriter(cw)
+	if err := h.renderToLocked(bw); err != nil {
+		return cw.n, err
+	}
+	err := bw.Flush()
+
+	return cw.n, err
+}
+
+// countingWriter counts the bytes written to w.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+// Write implements io.Writer.
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// inMemorySource names the config field the hosts were loaded from when it
+// is RawText or Reader rather than a file, or returns "".
+func (hc *HostsConfig) inMemorySource() string {
+	switch {
+	case hc == nil:
+		return ""
+	case hc.RawText != nil:
+		return "RawText"
+	case hc.Reader != nil:
+		return "Reader"
+	default:
+		return ""
+	}
+}
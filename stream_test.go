@@ -0,0 +1,174 @@
+package txeh
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestParseHostsReader_MatchesParseHostsFromString(t *testing.T) {
+	t.Parallel()
+
+	inputs := []string{
+		"",
+		utf8BOM,
+		"127.0.0.1 localhost\n",
+		"127.0.0.1 localhost",
+		"# comment\r\n\r\n10.0.0.1 a B # tag=x\r\n::1 localhost",
+		"garbage\n\n\n",
+		"10.0.0.1 a\rb\n",
+	}
+	for _, input := range inputs {
+		want, err := ParseHostsFromString(input)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Given: the input delivered one byte at a time
+		// When: it is parsed from a reader
+		got, err := ParseHostsReader(iotest.OneByteReader(strings.NewReader(input)))
+
+		// Then: the lines match the string parser
+		if err != nil {
+			t.Fatalf("ParseHostsReader(%q) error = %v", input, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("ParseHostsReader(%q) = %+v, want %+v", input, got, want)
+		}
+	}
+}
+
+func TestParseHostsReader_Gzip(t *testing.T) {
+	t.Parallel()
+
+	// Given: a compressed hosts file
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := io.WriteString(zw, "127.0.0.1 localhost\n10.0.0.1 app.local\n"); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// When: it is parsed through the decompressor
+	lines, err := ParseHostsReader(zr)
+
+	// Then: both lines are parsed
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lines) != 2 || lines[1].Hostnames[0] != "app.local" {
+		t.Errorf("ParseHostsReader() = %+v", lines)
+	}
+}
+
+func TestParseHostsReader_ReadError(t *testing.T) {
+	t.Parallel()
+
+	// Given: a reader that fails
+	boom := errors.New("boom")
+
+	// When: it is parsed
+	_, err := ParseHostsReader(io.MultiReader(strings.NewReader("127.0.0.1 localhost\n"), iotest.ErrReader(boom)))
+
+	// Then: the read error is returned
+	if !errors.Is(err, boom) {
+		t.Errorf("ParseHostsReader() error = %v, want %v", err, boom)
+	}
+}
+
+func TestNewHosts_Reader(t *testing.T) {
+	t.Parallel()
+
+	// Given: hosts content on a reader with CRLF line endings
+	input := "127.0.0.1 localhost\r\n"
+
+	// When: NewHosts reads it and a host is added
+	h, err := NewHosts(&HostsConfig{Reader: strings.NewReader(input)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.AddHost(testIPv4Alt, "app.local")
+
+	// Then: the result keeps the line endings and cannot be saved
+	want := "127.0.0.1        localhost\r\n192.168.1.1      app.local\r\n"
+	if got := h.RenderHostsFile(); got != want {
+		t.Errorf("RenderHostsFile() = %q, want %q", got, want)
+	}
+	if err := h.Save(); err == nil || !strings.Contains(err.Error(), "Reader") {
+		t.Errorf("Save() error = %v, want an error naming Reader", err)
+	}
+	if err := h.Reload(); err == nil {
+		t.Error("Reload() error = nil, want an error")
+	}
+}
+
+func TestNewHosts_ReaderError(t *testing.T) {
+	t.Parallel()
+
+	// Given: a reader that fails
+	boom := errors.New("boom")
+
+	// When: NewHosts reads it
+	_, err := NewHosts(&HostsConfig{Reader: iotest.ErrReader(boom)})
+
+	// Then: the error is returned
+	if !errors.Is(err, boom) {
+		t.Errorf("NewHosts() error = %v, want %v", err, boom)
+	}
+}
+
+func TestWriteTo_MatchesRenderHostsFile(t *testing.T) {
+	t.Parallel()
+
+	// Given: a file with a BOM, CRLF and no final newline
+	h := newRawHosts(t, utf8BOM+"# hosts\r\n127.0.0.1 localhost\r\n10.0.0.1 app.local")
+	h.AddHost(testIPv4Alt, "other.local")
+
+	// When: it is written to a buffer
+	var buf bytes.Buffer
+	n, err := h.WriteTo(&buf)
+
+	// Then: the output and count match the rendered string
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := h.RenderHostsFile()
+	if buf.String() != want {
+		t.Errorf("WriteTo() wrote %q, want %q", buf.String(), want)
+	}
+	if n != int64(len(want)) {
+		t.Errorf("WriteTo() = %d, want %d", n, len(want))
+	}
+}
+
+func TestWriteTo_WriteError(t *testing.T) {
+	t.Parallel()
+
+	// Given: a writer that fails
+	h := newRawHosts(t, testHostsLocalhost)
+	boom := errors.New("boom")
+
+	// When: the file is written to it
+	n, err := h.WriteTo(failingWriter{boom})
+
+	// Then: the error is returned and nothing is counted
+	if !errors.Is(err, boom) || n != 0 {
+		t.Errorf("WriteTo() = %d, %v, want 0, %v", n, err, boom)
+	}
+}
+
+// failingWriter fails every write with err.
+type failingWriter struct{ err error }
+
+func (w failingWriter) Write([]byte) (int, error) { return 0, w.err }
@@ -0,0 +1,156 @@
+package txeh
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// ErrInvalidSelector is returned when a tag selector cannot be parsed.
+var ErrInvalidSelector = errors.New("invalid tag selector")
+
+// ErrInvalidTag is returned when a tag key or value cannot be written into an
+// inline comment.
+var ErrInvalidTag = errors.New("invalid tag")
+
+// tagKeyRegex matches valid tag keys.
+var tagKeyRegex = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_./-]*$`)
+
+// Selector matches lines by tag. A line matches when every key in the
+// selector is present in its tags with the same value.
+type Selector map[string]string
+
+// ParseSelector parses a comma-separated list of key=value pairs such as
+// "owner=kubefwd,ns=default". An empty selector is rejected so that a typo
+// can never match (and remove) every tagged line.
+func ParseSelector(s string) (Selector, error) {
+	sel := Selector{}
+	for pair := range strings.SplitSeq(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		k, v, ok := strings.Cut(pair, "=")
+		k, v = strings.TrimSpace(k), strings.TrimSpace(v)
+		if !ok || !tagKeyRegex.MatchString(k) || !validTagValue(v) {
+			return nil, fmt.Errorf("%q: %w", pair, ErrInvalidSelector)
+		}
+		sel[k] = v
+	}
+
+	if len(sel) == 0 {
+		return nil, fmt.Errorf("%q: %w: no key=value pairs", s, ErrInvalidSelector)
+	}
+
+	return sel, nil
+}
+
+// Matches reports whether tags satisfy every requirement of the selector.
+func (s Selector) Matches(tags map[string]string) bool {
+	for k, v := range s {
+		if got, ok := tags[k]; !ok || got != v {
+			return false
+		}
+	}
+	return true
+}
+
+// ParseTags extracts the key=value tokens of an inline comment. Other words
+// are free-form text and are ignored, so "kubefwd owner=kubefwd ns=default"
+// yields {owner: kubefwd, ns: default}. It returns nil when the comment has
+// no tags. When a key repeats, the last value wins.
+func ParseTags(comment string) map[string]string {
+	var tags map[string]string
+	for _, tok := range strings.Fields(comment) {
+		k, v, ok := strings.Cut(tok, "=")
+		if !ok || !tagKeyRegex.MatchString(k) || v == "" {
+			continue
+		}
+		if tags == nil {
+			tags = make(map[string]string)
+		}
+		tags[k] = v
+	}
+	return tags
+}
+
+// FormatTags renders tags as an inline comment, sorted by key so equal tag
+// sets always produce the same comment (and share a line).
+func FormatTags(tags map[string]string) (string, error) {
+	pairs := make([]string, 0, len(tags))
+	for _, k := range slices.Sorted(maps.Keys(tags)) {
+		v := tags[k]
+		if !tagKeyRegex.MatchString(k) || !validTagValue(v) {
+			return "", fmt.Errorf("%q=%q: %w", k, v, ErrInvalidTag)
+		}
+		pairs = append(pairs, k+"="+v)
+	}
+	return strings.Join(pairs, " "), nil
+}
+
+// validTagValue reports whether v can be stored as a tag value.
+func validTagValue(v string) bool {
+	return v != "" && !strings.ContainsAny(v, " \t#,\r\n\v\f\x00")
+}
+
+// AddHostWithTags adds a host to an address with the tags written as the
+// inline comment (e.g. "127.0.0.1 host # ns=default owner=kubefwd"). It
+// follows the same rules as AddHostWithComment and validates like AddHostE.
+func (h *Hosts) AddHostWithTags(address, host string, tags map[string]string) error {
+	return h.AddHostsWithTags(address, []string{host}, tags)
+}
+
+// AddHostsWithTags adds hosts to an address with the tags written as the
+// inline comment. It follows the same rules as AddHostsWithComment and
+// validates like AddHostsE.
+func (h *Hosts) AddHostsWithTags(address string, hosts []string, tags map[string]string) error {
+	comment, err := FormatTags(tags)
+	if err != nil {
+		return err
+	}
+	return h.AddHostsWithCommentE(address, hosts, comment)
+}
+
+// ListHostsBySelector returns all hostnames on lines whose tags match the
+// selector (see ParseSelector).
+func (h *Hosts) ListHostsBySelector(selector string) ([]string, error) {
+	sel, err := ParseSelector(selector)
+	if err != nil {
+		return nil, err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var hosts []string
+	for _, hfl := range h.hostFileLines {
+		if hfl.LineType == ADDRESS && sel.Matches(hfl.Tags) {
+			hosts = append(hosts, hfl.Hostnames...)
+		}
+	}
+
+	return hosts, nil
+}
+
+// RemoveBySelector removes all lines whose tags match the selector
+// (see ParseSelector).
+func (h *Hosts) RemoveBySelector(selector string) error {
+	sel, err := ParseSelector(selector)
+	if err != nil {
+		return err
+	}
+
+	h.mutate(func(x *Hosts) { x.removeBySelectorLocked(sel) })
+	return nil
+}
+
+// removeBySelectorLocked removes all address lines matching sel.
+// Must be called with the lock held.
+func (h *Hosts) removeBySelectorLocked(sel Selector) {
+	h.deleteLinesLocked(func(hfl *HostFileLine) bool {
+		return hfl.LineType == ADDRESS && sel.Matches(hfl.Tags)
+	})
+}
@@ -0,0 +1,133 @@
+package txeh
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+const testTaggedHosts = `127.0.0.1        localhost
+127.1.27.1       svc-a # owner=kubefwd ns=default
+127.1.27.2       svc-b # kubefwd owner=kubefwd ns=staging
+127.1.27.3       svc-c # owner=vpn
+10.0.0.1         plain # dev services
+`
+
+func TestParseTags(t *testing.T) {
+	tests := []struct {
+		comment string
+		want    map[string]string
+	}{
+		{"", nil},
+		{"dev services", nil},
+		{"owner=kubefwd ns=default", map[string]string{"owner": "kubefwd", "ns": "default"}},
+		{"managed owner=x", map[string]string{"owner": "x"}},
+		{"expires=2026-10-16T12:00Z", map[string]string{"expires": "2026-10-16T12:00Z"}},
+		{"a= =b a=1 a=2", map[string]string{"a": "2"}},
+	}
+	for _, tt := range tests {
+		got := ParseTags(tt.comment)
+		if len(got) != len(tt.want) {
+			t.Errorf("ParseTags(%q) = %v, want %v", tt.comment, got, tt.want)
+			continue
+		}
+		for k, v := range tt.want {
+			if got[k] != v {
+				t.Errorf("ParseTags(%q)[%q] = %q, want %q", tt.comment, k, got[k], v)
+			}
+		}
+	}
+}
+
+func TestParseSelector(t *testing.T) {
+	sel, err := ParseSelector(" owner=kubefwd , ns=default ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sel) != 2 || sel["owner"] != "kubefwd" || sel["ns"] != "default" {
+		t.Errorf("unexpected selector %v", sel)
+	}
+
+	for _, bad := range []string{"", ",", "owner", "owner=", "=x", "a b=c"} {
+		if _, err := ParseSelector(bad); !errors.Is(err, ErrInvalidSelector) {
+			t.Errorf("ParseSelector(%q) expected ErrInvalidSelector, got %v", bad, err)
+		}
+	}
+}
+
+// Given lines tagged with several dimensions
+// When ListHostsBySelector is called
+// Then only lines matching every pair are returned, including those with free-form text.
+func TestListHostsBySelector(t *testing.T) {
+	hosts := newRawHosts(t, testTaggedHosts)
+
+	got, err := hosts.ListHostsBySelector("owner=kubefwd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(got, []string{"svc-a", "svc-b"}) {
+		t.Errorf("owner=kubefwd: got %v", got)
+	}
+
+	got, _ = hosts.ListHostsBySelector("owner=kubefwd,ns=staging")
+	if !slices.Equal(got, []string{"svc-b"}) {
+		t.Errorf("owner=kubefwd,ns=staging: got %v", got)
+	}
+
+	if _, err := hosts.ListHostsBySelector("nope"); err == nil {
+		t.Error("expected selector error")
+	}
+
+	// Free-form comments keep working with exact matching.
+	if got := hosts.ListHostsByComment("dev services"); !slices.Equal(got, []string{"plain"}) {
+		t.Errorf("ListHostsByComment: got %v", got)
+	}
+}
+
+// Given tagged lines
+// When RemoveBySelector is called
+// Then matching lines are removed and the rest stay.
+func TestRemoveBySelector(t *testing.T) {
+	hosts := newRawHosts(t, testTaggedHosts)
+
+	if err := hosts.RemoveBySelector("owner=kubefwd,ns=default"); err != nil {
+		t.Fatal(err)
+	}
+	if hosts.ListHostsByIP("127.1.27.1") != nil {
+		t.Error("svc-a should be removed")
+	}
+	if hosts.ListHostsByIP("127.1.27.2") == nil || hosts.ListHostsByIP("127.0.0.1") == nil {
+		t.Error("non-matching lines were removed")
+	}
+	if err := hosts.RemoveBySelector(""); !errors.Is(err, ErrInvalidSelector) {
+		t.Errorf("empty selector should be rejected, got %v", err)
+	}
+}
+
+// Given tags in arbitrary map order
+// When AddHostsWithTags is called twice with the same tags
+// Then the comment is rendered sorted and the hosts share one line.
+func TestAddHostsWithTags(t *testing.T) {
+	hosts := newRawHosts(t, testHostsLocalhost)
+
+	tags := map[string]string{"owner": "kubefwd", "ns": "default"}
+	if err := hosts.AddHostsWithTags("127.1.27.1", []string{"a"}, tags); err != nil {
+		t.Fatal(err)
+	}
+	if err := hosts.AddHostWithTags("127.1.27.1", "b", map[string]string{"ns": "default", "owner": "kubefwd"}); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := hosts.GetHostFileLines()
+	last := lines[len(lines)-1]
+	if last.Comment != "ns=default owner=kubefwd" || !slices.Equal(last.Hostnames, []string{"a", "b"}) {
+		t.Errorf("unexpected line %+v", last)
+	}
+	if last.Tags["owner"] != "kubefwd" {
+		t.Errorf("Tags not set on new line: %v", last.Tags)
+	}
+
+	if err := hosts.AddHostWithTags("127.1.27.1", "c", map[string]string{"owner": "two words"}); !errors.Is(err, ErrInvalidTag) {
+		t.Errorf("expected ErrInvalidTag, got %v", err)
+	}
+}
@@ -0,0 +1,108 @@
+package txeh
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"time"
+)
+
+// TagExpires is the tag holding the expiry time of an entry added with a TTL,
+// e.g. "127.0.0.1 tmp.local # expires=2026-10-16T12:00:00Z".
+const TagExpires = "expires"
+
+// expiresShortLayout is accepted when parsing expiry tags written by hand
+// without seconds, e.g. expires=2026-10-16T12:00Z.
+const expiresShortLayout = "2006-01-02T15:04Z07:00"
+
+// ttlNow returns the current time for TTL expiry stamps. Tests replace it.
+var ttlNow = time.Now
+
+// FormatExpires renders t as an expires tag value (RFC 3339, UTC, whole seconds).
+func FormatExpires(t time.Time) string {
+	return t.UTC().Truncate(time.Second).Format(time.RFC3339)
+}
+
+// ParseExpires parses an expires tag value. Both RFC 3339 and the shorter
+// form without seconds (2026-10-16T12:00Z) are accepted.
+func ParseExpires(v string) (time.Time, bool) {
+	for _, layout := range []string{time.RFC3339, expiresShortLayout} {
+		if t, err := time.Parse(layout, v); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// AddHostWithTTL adds a host to an address with an expiry ttl from now,
+// stored as an expires tag in the inline comment. Re-adding a host that is
+// already at the address replaces its expiry. Use PruneExpired to remove
+// entries whose expiry has passed.
+func (h *Hosts) AddHostWithTTL(address, host string, ttl time.Duration) error {
+	return h.AddHostsWithTTL(address, []string{host}, ttl)
+}
+
+// AddHostsWithTTL adds hosts to an address with an expiry ttl from now.
+// See AddHostWithTTL.
+func (h *Hosts) AddHostsWithTTL(address string, hosts []string, ttl time.Duration) error {
+	return h.AddHostsWithCommentTTL(address, hosts, "", ttl)
+}
+
+// AddHostsWithCommentTTL adds hosts to an address with an inline comment
+// followed by an expires tag ttl from now. Any expires tag already present in
+// comment is replaced. Invalid input is rejected as by AddHostsE.
+// See AddHostWithTTL.
+func (h *Hosts) AddHostsWithCommentTTL(address string, hosts []string, comment string, ttl time.Duration) error {
+	if ttl <= 0 {
+		return errors.New("ttl must be positive")
+	}
+	return h.AddHostsWithOptions(address, hosts, AddOptions{Comment: comment, TTL: ttl})
+}
+
+// expiringComment returns comment with its expires tag set to ttl from now.
+func expiringComment(comment string, ttl time.Duration) string {
+	words := slices.DeleteFunc(strings.Fields(stripLineBreaks(comment)), func(w string) bool {
+		return strings.HasPrefix(w, TagExpires+"=")
+	})
+	return strings.Join(append(words, TagExpires+"="+FormatExpires(ttlNow().Add(ttl))), " ")
+}
+
+// PruneExpired removes every address line whose expires tag is at or before
+// now and returns the hostnames that were removed. Lines with a missing or
+// unparsable expires tag are kept.
+func (h *Hosts) PruneExpired(now time.Time) []string {
+	var pruned []string
+	h.mutate(func(x *Hosts) { pruned = x.pruneExpiredLocked(now) })
+	return pruned
+}
+
+// pruneExpiredLocked removes expired lines. Must be called with the lock held.
+func (h *Hosts) pruneExpiredLocked(now time.Time) []string {
+	var pruned []string
+	h.deleteLinesLocked(func(hfl *HostFileLine) bool {
+		if hfl.LineType != ADDRESS {
+			return false
+		}
+		exp, ok := ParseExpires(hfl.Tags[TagExpires])
+		if !ok || exp.After(now) {
+			return false
+		}
+		pruned = append(pruned, hfl.Hostnames...)
+		return true
+	})
+	return pruned
+}
+
+// removeHostAtAddressLocked removes host from lines with address, dropping
+// lines left without hostnames. Must be called with the lock held.
+func (h *Hosts) removeHostAtAddressLocked(address, host string) {
+	m := newAddressMatcher(address)
+	host = canonicalHostname(host)
+	var positions []int
+	for _, i := range h.hostLines(host, host) {
+		if m.matches(&h.hostFileLines[i]) {
+			positions = append(positions, i)
+		}
+	}
+	h.removeHostsAtLocked(positions, host)
+}
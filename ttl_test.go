@@ -0,0 +1,113 @@
+package txeh
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// stubTTLClock makes AddHostWithTTL stamp expiries relative to now.
+func stubTTLClock(t *testing.T, now time.Time) {
+	t.Helper()
+	orig := ttlNow
+	ttlNow = func() time.Time { return now }
+	t.Cleanup(func() { ttlNow = orig })
+}
+
+// Given a fixed clock
+// When AddHostWithTTL is called
+// Then the expiry is stored as an RFC 3339 UTC expires tag.
+func TestAddHostWithTTL_StoresExpiresTag(t *testing.T) {
+	stubTTLClock(t, time.Date(2026, 10, 16, 10, 0, 0, 500, time.UTC))
+	hosts := newRawHosts(t, testHostsLocalhost)
+
+	if err := hosts.AddHostWithTTL(testIPv4Alt, "tmp.local", 2*time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	want := testIPv4Alt + "      tmp.local # expires=2026-10-16T12:00:00Z\n"
+	if got := hosts.RenderHostsFile(); !strings.HasSuffix(got, want) {
+		t.Errorf("got:\n%s\nwant suffix %q", got, want)
+	}
+
+	if err := hosts.AddHostWithTTL(testIPv4Alt, "x", 0); err == nil {
+		t.Error("expected error for zero ttl")
+	}
+}
+
+// Given a host already added with a TTL
+// When it is added again with a longer TTL
+// Then its expiry is replaced rather than duplicated.
+func TestAddHostWithTTL_ReAddExtends(t *testing.T) {
+	start := time.Date(2026, 10, 16, 10, 0, 0, 0, time.UTC)
+	stubTTLClock(t, start)
+	hosts := newRawHosts(t, testHostsLocalhost)
+
+	_ = hosts.AddHostWithTTL(testIPv4Alt, "tmp.local", time.Hour)
+	_ = hosts.AddHostWithTTL(testIPv4Alt, "tmp.local", 3*time.Hour)
+
+	if got := hosts.ListHostsByIP(testIPv4Alt); !slices.Equal(got, []string{"tmp.local"}) {
+		t.Fatalf("expected a single entry, got %v", got)
+	}
+	if pruned := hosts.PruneExpired(start.Add(2 * time.Hour)); len(pruned) != 0 {
+		t.Errorf("entry pruned before its extended expiry: %v", pruned)
+	}
+}
+
+// Given a comment with free text, tags and a stale expires tag
+// When AddHostsWithCommentTTL is called
+// Then the stale expiry is replaced and the rest is kept.
+func TestAddHostsWithCommentTTL(t *testing.T) {
+	stubTTLClock(t, time.Date(2026, 10, 16, 10, 0, 0, 0, time.UTC))
+	hosts := newRawHosts(t, testHostsLocalhost)
+
+	err := hosts.AddHostsWithCommentTTL(testIPv4Alt, []string{"a", "b"}, "demo owner=me expires=2000-01-01T00:00Z", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := hosts.GetHostFileLines()
+	last := lines[len(lines)-1]
+	if last.Comment != "demo owner=me expires=2026-10-16T11:00:00Z" {
+		t.Errorf("unexpected comment %q", last.Comment)
+	}
+}
+
+// Given entries with past, future, short-form and malformed expiries
+// When PruneExpired runs
+// Then only the expired lines are removed.
+func TestPruneExpired(t *testing.T) {
+	hosts := newRawHosts(t, `127.0.0.1        localhost
+10.0.0.1         old-a old-b # expires=2026-10-16T09:00:00Z
+10.0.0.2         short # owner=x expires=2026-10-16T09:30Z
+10.0.0.3         future # expires=2026-10-16T11:00:00Z
+10.0.0.4         bogus # expires=tomorrow
+10.0.0.5         edge # expires=2026-10-16T10:00:00Z
+# expires=2000-01-01T00:00:00Z
+`)
+
+	pruned := hosts.PruneExpired(time.Date(2026, 10, 16, 10, 0, 0, 0, time.UTC))
+	if !slices.Equal(pruned, []string{"old-a", "old-b", "short", "edge"}) {
+		t.Errorf("pruned = %v", pruned)
+	}
+
+	got := hosts.RenderHostsFile()
+	for _, keep := range []string{"future", "bogus", "localhost", "# expires=2000"} {
+		if !strings.Contains(got, keep) {
+			t.Errorf("%q should be kept:\n%s", keep, got)
+		}
+	}
+}
+
+func TestParseExpires(t *testing.T) {
+	want := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	for _, v := range []string{"2026-10-16T12:00:00Z", "2026-10-16T12:00Z", "2026-10-16T14:00+02:00"} {
+		got, ok := ParseExpires(v)
+		if !ok || !got.Equal(want) {
+			t.Errorf("ParseExpires(%q) = %v, %v", v, got, ok)
+		}
+	}
+	if _, ok := ParseExpires("2026-10-16"); ok {
+		t.Error("date without time should not parse")
+	}
+}
@@ -0,0 +1,325 @@
+package txeh
+
+import (
+	"iter"
+	"maps"
+	"slices"
+	"time"
+)
+
+// Tx is a batch of changes to a Hosts instance, passed to the callback of
+// Hosts.Update. Its methods mirror the mutation and query methods of Hosts but
+// act on a private working copy: nothing is visible to other goroutines until
+// the callback returns nil, and everything is discarded if it returns an error.
+//
+// A Tx must only be used inside the Update callback that received it.
+type Tx struct {
+	work *Hosts
+}
+
+// Update runs fn as a single transaction. The instance lock is held for the
+// whole call, so no other goroutine can observe or save a half-applied batch.
+// If fn returns an error (or panics) every change made through tx is discarded
+// and the error is returned. If fn returns nil the changes are committed and
+// the hosts file is saved exactly once.
+//
+// With LockTimeout set, the cross-process lock is also held from the start of
+// the transaction through the save. Instances created from RawText or Reader commit the
+// changes in memory only, since there is nothing to save to.
+//
+// A failed save (for example ErrConcurrentModification) leaves the committed
+// changes in memory, exactly like a failed Save after individual mutations, so
+// the caller can retry with SaveMerge.
+//
+// fn must use tx rather than calling methods on h, which would deadlock.
+func (h *Hosts) Update(fn func(tx *Tx) error) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	ownLock := false
+	if h.nonFileSource() == "" && h.LockTimeout > 0 && h.fileLock == nil {
+		if err := h.acquireLockLocked(h.LockTimeout); err != nil {
+			return err
+		}
+		defer func() {
+			if ownLock {
+				_ = h.releaseLockLocked()
+			}
+		}()
+		ownLock = true
+	}
+
+	tx := &Tx{work: &Hosts{
+		HostsConfig:   h.HostsConfig,
+		hostFileLines: cloneHostFileLines(h.hostFileLines),
+		format:        h.format,
+	}}
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	h.hostFileLines = tx.work.hostFileLines
+	h.index = tx.work.index
+	h.pending = append(h.pending, tx.work.pending...)
+
+	if h.inMemorySource() != "" {
+		return nil
+	}
+	if s := h.customStore(); s != nil {
+		return h.saveToStoreLocked(s)
+	}
+
+	// From here the write path owns the lock and releases it after the save.
+	ownLock = false
+	release, err := h.lockForWriteLocked(h.WriteFilePath)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = release() }()
+
+	if err := h.checkUnmodifiedLocked(h.WriteFilePath); err != nil {
+		return err
+	}
+
+	return h.writeLocked(h.WriteFilePath)
+}
+
+// cloneHostFileLines returns a deep copy of lines, so mutations of the copy
+// never reach the slices backing the original.
+func cloneHostFileLines(lines HostFileLines) HostFileLines {
+	out := make(HostFileLines, len(lines))
+	for i, l := range lines {
+		l.Parts = slices.Clone(l.Parts)
+		l.Hostnames = slices.Clone(l.Hostnames)
+		l.Tags = maps.Clone(l.Tags)
+		out[i] = l
+	}
+	return out
+}
+
+// AddHost adds a host to an address within the transaction. See Hosts.AddHost.
+func (tx *Tx) AddHost(address, host string) {
+	tx.work.AddHost(address, host)
+}
+
+// AddHosts adds hosts to an address within the transaction. See Hosts.AddHosts.
+func (tx *Tx) AddHosts(address string, hosts []string) {
+	tx.work.AddHosts(address, hosts)
+}
+
+// AddHostWithComment adds a host with an inline comment within the transaction.
+// See Hosts.AddHostWithComment.
+func (tx *Tx) AddHostWithComment(address, host, comment string) {
+	tx.work.AddHostWithComment(address, host, comment)
+}
+
+// AddHostsWithComment adds hosts with an inline comment within the transaction.
+// See Hosts.AddHostsWithComment.
+func (tx *Tx) AddHostsWithComment(address string, hosts []string, comment string) {
+	tx.work.AddHostsWithComment(address, hosts, comment)
+}
+
+// AddHostE adds a host to an address with validation within the transaction.
+// See Hosts.AddHostE.
+func (tx *Tx) AddHostE(address, host string) error {
+	return tx.work.AddHostE(address, host)
+}
+
+// AddHostWithCommentE adds a host with a comment and validation within the
+// transaction. See Hosts.AddHostWithCommentE.
+func (tx *Tx) AddHostWithCommentE(address, host, comment string) error {
+	return tx.work.AddHostWithCommentE(address, host, comment)
+}
+
+// AddHostsE adds hosts to an address with validation within the transaction.
+// See Hosts.AddHostsE.
+func (tx *Tx) AddHostsE(address string, hosts []string) error {
+	return tx.work.AddHostsE(address, hosts)
+}
+
+// AddHostsWithCommentE adds hosts with a comment and validation within the
+// transaction. See Hosts.AddHostsWithCommentE.
+func (tx *Tx) AddHostsWithCommentE(address string, hosts []string, comment string) error {
+	return tx.work.AddHostsWithCommentE(address, hosts, comment)
+}
+
+// RemoveHost removes all entries of host within the transaction. See Hosts.RemoveHost.
+func (tx *Tx) RemoveHost(host string) {
+	tx.work.RemoveHost(host)
+}
+
+// RemoveHosts removes all entries of hosts within the transaction. See Hosts.RemoveHosts.
+func (tx *Tx) RemoveHosts(hosts []string) {
+	tx.work.RemoveHosts(hosts)
+}
+
+// RemoveFirstHost removes the first entry of host within the transaction.
+// See Hosts.RemoveFirstHost.
+func (tx *Tx) RemoveFirstHost(host string) bool {
+	return tx.work.RemoveFirstHost(host)
+}
+
+// RemoveAddress removes all lines with address within the transaction.
+// See Hosts.RemoveAddress.
+func (tx *Tx) RemoveAddress(address string) {
+	tx.work.RemoveAddress(address)
+}
+
+// RemoveAddresses removes all lines with any of addresses within the transaction.
+// See Hosts.RemoveAddresses.
+func (tx *Tx) RemoveAddresses(addresses []string) {
+	tx.work.RemoveAddresses(addresses)
+}
+
+// RemoveFirstAddress removes the first line with address within the transaction.
+// See Hosts.RemoveFirstAddress.
+func (tx *Tx) RemoveFirstAddress(address string) bool {
+	return tx.work.RemoveFirstAddress(address)
+}
+
+// RemoveCIDRs removes all lines with an address in any of cidrs within the
+// transaction. See Hosts.RemoveCIDRs.
+func (tx *Tx) RemoveCIDRs(cidrs []string) error {
+	return tx.work.RemoveCIDRs(cidrs)
+}
+
+// RemoveByComment removes all lines with comment within the transaction.
+// See Hosts.RemoveByComment.
+func (tx *Tx) RemoveByComment(comment string) {
+	tx.work.RemoveByComment(comment)
+}
+
+// RemoveByComments removes all lines with any of comments within the
+// transaction. See Hosts.RemoveByComments.
+func (tx *Tx) RemoveByComments(comments []string) {
+	tx.work.RemoveByComments(comments)
+}
+
+// AddHostWithTags adds a host with tags within the transaction.
+// See Hosts.AddHostWithTags.
+func (tx *Tx) AddHostWithTags(address, host string, tags map[string]string) error {
+	return tx.work.AddHostWithTags(address, host, tags)
+}
+
+// AddHostsWithTags adds hosts with tags within the transaction.
+// See Hosts.AddHostsWithTags.
+func (tx *Tx) AddHostsWithTags(address string, hosts []string, tags map[string]string) error {
+	return tx.work.AddHostsWithTags(address, hosts, tags)
+}
+
+// RemoveBySelector removes all lines matching a tag selector within the
+// transaction. See Hosts.RemoveBySelector.
+func (tx *Tx) RemoveBySelector(selector string) error {
+	return tx.work.RemoveBySelector(selector)
+}
+
+// AddHostWithTTL adds a host with an expiry within the transaction.
+// See Hosts.AddHostWithTTL.
+func (tx *Tx) AddHostWithTTL(address, host string, ttl time.Duration) error {
+	return tx.work.AddHostWithTTL(address, host, ttl)
+}
+
+// AddHostsWithTTL adds hosts with an expiry within the transaction.
+// See Hosts.AddHostsWithTTL.
+func (tx *Tx) AddHostsWithTTL(address string, hosts []string, ttl time.Duration) error {
+	return tx.work.AddHostsWithTTL(address, hosts, ttl)
+}
+
+// AddHostsWithCommentTTL adds hosts with a comment and an expiry within the
+// transaction. See Hosts.AddHostsWithCommentTTL.
+func (tx *Tx) AddHostsWithCommentTTL(address string, hosts []string, comment string, ttl time.Duration) error {
+	return tx.work.AddHostsWithCommentTTL(address, hosts, comment, ttl)
+}
+
+// PruneExpired removes expired entries within the transaction.
+// See Hosts.PruneExpired.
+func (tx *Tx) PruneExpired(now time.Time) []string {
+	return tx.work.PruneExpired(now)
+}
+
+// SetBlock replaces the content of a managed block within the transaction.
+// See Hosts.SetBlock.
+func (tx *Tx) SetBlock(name string, entries []BlockEntry) error {
+	return tx.work.SetBlock(name, entries)
+}
+
+// RemoveBlock deletes a managed block within the transaction. See Hosts.RemoveBlock.
+func (tx *Tx) RemoveBlock(name string) bool {
+	return tx.work.RemoveBlock(name)
+}
+
+// Block returns the entries of a managed block as seen inside the transaction.
+func (tx *Tx) Block(name string) ([]BlockEntry, bool) {
+	return tx.work.Block(name)
+}
+
+// ListHostsByIP returns the hostnames at address as seen inside the transaction.
+func (tx *Tx) ListHostsByIP(address string) []string {
+	return tx.work.ListHostsByIP(address)
+}
+
+// ListAddressesByHost returns the addresses of hostname as seen inside the
+// transaction. See Hosts.ListAddressesByHost.
+func (tx *Tx) ListAddressesByHost(hostname string, exact bool) [][]string {
+	return tx.work.ListAddressesByHost(hostname, exact)
+}
+
+// ListHostsByComment returns the hostnames on lines with comment as seen
+// inside the transaction.
+func (tx *Tx) ListHostsByComment(comment string) []string {
+	return tx.work.ListHostsByComment(comment)
+}
+
+// ListHostsBySelector returns the hostnames on lines matching a tag selector
+// as seen inside the transaction.
+func (tx *Tx) ListHostsBySelector(selector string) ([]string, error) {
+	return tx.work.ListHostsBySelector(selector)
+}
+
+// HostAddressLookup looks up host as seen inside the transaction.
+// See Hosts.HostAddressLookup.
+func (tx *Tx) HostAddressLookup(host string, ipFamily IPFamily) (found bool, address string, idx int) {
+	return tx.work.HostAddressLookup(host, ipFamily)
+}
+
+// RenderHostsFile renders the hosts file as it would be saved if the
+// transaction committed now.
+func (tx *Tx) RenderHostsFile() string {
+	return tx.work.RenderHostsFile()
+}
+
+// Lint checks the hosts file as it would be saved if the transaction
+// committed now. See Hosts.Lint.
+func (tx *Tx) Lint() []Diagnostic {
+	return tx.work.Lint()
+}
+
+// Normalize rewrites address lines inside the transaction. See Hosts.Normalize.
+func (tx *Tx) Normalize(opts NormalizeOptions) {
+	tx.work.Normalize(opts)
+}
+
+// Entries returns the hostname mappings as seen inside the transaction.
+// See Hosts.Entries.
+func (tx *Tx) Entries() iter.Seq[Entry] {
+	return tx.work.Entries()
+}
+
+// Query returns the entries matching q as seen inside the transaction.
+// See Hosts.Query.
+func (tx *Tx) Query(q Query) iter.Seq[Entry] {
+	return tx.work.Query(q)
+}
+
+// AddHostWithOptions adds a host within the transaction.
+// See Hosts.AddHostWithOptions.
+func (tx *Tx) AddHostWithOptions(address, host string, opts AddOptions) error {
+	return tx.work.AddHostWithOptions(address, host, opts)
+}
+
+// AddHostsWithOptions adds hosts within the transaction.
+// See Hosts.AddHostsWithOptions.
+func (tx *Tx) AddHostsWithOptions(address string, hosts []string, opts AddOptions) error {
+	return tx.work.AddHostsWithOptions(address, hosts, opts)
+}
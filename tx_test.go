@@ -0,0 +1,250 @@
+package txeh
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Given a transaction that adds and removes hosts
+// When the callback returns nil
+// Then the changes are applied in memory and saved in a single write.
+func TestUpdate_Commit_SavesOnce(t *testing.T) {
+	path := writeHostsFile(t, "127.0.0.1 localhost\n10.0.0.1 old\n")
+
+	hosts, err := NewHosts(&HostsConfig{ReadFilePath: path, Backup: true, BackupRetention: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = hosts.Update(func(tx *Tx) error {
+		tx.RemoveHost("old")
+		tx.AddHost(testIPv4Alt, "new-a")
+		tx.AddHostWithComment(testIPv4Alt, "new-b", "batch")
+		if got := tx.ListHostsByIP(testIPv4Alt); len(got) != 2 {
+			t.Errorf("tx should see its own changes, got %v", got)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Update() error: %v", err)
+	}
+
+	got := readHostsFile(t, path)
+	if strings.Contains(got, "old") || !strings.Contains(got, "new-a") || !strings.Contains(got, "new-b") {
+		t.Errorf("unexpected file content %q", got)
+	}
+	if hosts.ListHostsByIP("10.0.0.1") != nil {
+		t.Error("in-memory state should reflect the committed transaction")
+	}
+
+	backups, err := hosts.ListBackups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 1 {
+		t.Errorf("expected exactly one write (one backup), got %d", len(backups))
+	}
+}
+
+// Given a transaction whose callback returns an error after making changes
+// When Update returns
+// Then the error is passed through and neither memory nor the file changed.
+func TestUpdate_Error_Discards(t *testing.T) {
+	content := "127.0.0.1 localhost\n10.0.0.1 keep-a keep-b\n"
+	path := writeHostsFile(t, content)
+
+	hosts, err := NewHosts(&HostsConfig{ReadFilePath: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := hosts.RenderHostsFile()
+
+	errAbort := errors.New("abort")
+	err = hosts.Update(func(tx *Tx) error {
+		tx.RemoveHost("keep-a")
+		tx.AddHost(testIPv4Alt, "discarded")
+		return errAbort
+	})
+	if !errors.Is(err, errAbort) {
+		t.Fatalf("expected callback error, got %v", err)
+	}
+
+	if got := hosts.RenderHostsFile(); got != before {
+		t.Errorf("in-memory state changed:\n%s", got)
+	}
+	if got := readHostsFile(t, path); got != content {
+		t.Errorf("file changed: %q", got)
+	}
+
+	// A discarded transaction leaves nothing for SaveMerge to replay.
+	if err := hosts.SaveMerge(); err != nil {
+		t.Fatal(err)
+	}
+	if got := readHostsFile(t, path); strings.Contains(got, "discarded") || !strings.Contains(got, "keep-a") {
+		t.Errorf("discarded changes were replayed: %q", got)
+	}
+}
+
+// Given a transaction that fails partway through an invalid CIDR
+// When Update returns
+// Then the earlier changes in the batch are rolled back too.
+func TestUpdate_InvalidCIDR_RollsBack(t *testing.T) {
+	raw := "127.0.0.1 localhost\n10.0.0.1 a\n"
+	hosts, err := NewHosts(&HostsConfig{RawText: &raw})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = hosts.Update(func(tx *Tx) error {
+		tx.RemoveAddress("10.0.0.1")
+		return tx.RemoveCIDRs([]string{"not-a-cidr"})
+	})
+	if err == nil {
+		t.Fatal("expected error from invalid CIDR")
+	}
+	if hosts.ListHostsByIP("10.0.0.1") == nil {
+		t.Error("RemoveAddress should have been rolled back")
+	}
+}
+
+// Given tagged lines
+// When they are cloned for a transaction and the copy's tags change
+// Then the original tags are untouched, so a rollback keeps them.
+func TestCloneHostFileLines_ClonesTags(t *testing.T) {
+	lines, err := ParseHostsFromString("10.0.0.1 a # env=dev owner=ops\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clone := cloneHostFileLines(lines)
+	clone[0].Tags["env"] = "prod"
+	delete(clone[0].Tags, "owner")
+
+	if lines[0].Tags["env"] != "dev" || lines[0].Tags["owner"] != "ops" {
+		t.Errorf("original tags changed: %v", lines[0].Tags)
+	}
+}
+
+// Given a RawText instance
+// When a transaction commits
+// Then the changes are applied in memory and no save is attempted.
+func TestUpdate_RawText_CommitsInMemory(t *testing.T) {
+	raw := testHostsLocalhost
+	hosts, err := NewHosts(&HostsConfig{RawText: &raw})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := hosts.Update(func(tx *Tx) error {
+		tx.AddHosts(testIPv4Alt, []string{"a", "b"})
+		return nil
+	}); err != nil {
+		t.Fatalf("Update() error: %v", err)
+	}
+	if got := hosts.ListHostsByIP(testIPv4Alt); len(got) != 2 {
+		t.Errorf("expected 2 hosts, got %v", got)
+	}
+}
+
+// Given a transaction in progress
+// When another goroutine reads the hosts
+// Then it never observes a partially applied batch.
+func TestUpdate_Concurrent_NoPartialState(t *testing.T) {
+	raw := "127.0.0.1 localhost\n"
+	hosts, err := NewHosts(&HostsConfig{RawText: &raw})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	started := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		_ = hosts.Update(func(tx *Tx) error {
+			tx.AddHost(testIPv4Alt, "first")
+			close(started)
+			time.Sleep(50 * time.Millisecond)
+			tx.AddHost(testIPv4Alt, "second")
+			return nil
+		})
+	}()
+
+	<-started
+	got := hosts.ListHostsByIP(testIPv4Alt)
+	wg.Wait()
+	if len(got) != 2 {
+		t.Errorf("reader saw %v, want both hosts of the committed batch", got)
+	}
+}
+
+// Given a hosts file modified by another tool after load
+// When a transaction commits
+// Then the save fails with ErrConcurrentModification and the other tool's content is kept.
+func TestUpdate_ExternalModification(t *testing.T) {
+	path := writeHostsFile(t, testHostsLocalhost)
+
+	hosts, err := NewHosts(&HostsConfig{ReadFilePath: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	external := testHostsLocalhost + "10.0.0.9 theirs\n"
+	if err := os.WriteFile(path, []byte(external), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	err = hosts.Update(func(tx *Tx) error {
+		tx.AddHost(testIPv4Alt, "mine")
+		return nil
+	})
+	if !errors.Is(err, ErrConcurrentModification) {
+		t.Fatalf("expected ErrConcurrentModification, got %v", err)
+	}
+	if got := readHostsFile(t, path); got != external {
+		t.Errorf("file was overwritten: %q", got)
+	}
+
+	if err := hosts.SaveMerge(); err != nil {
+		t.Fatal(err)
+	}
+	got := readHostsFile(t, path)
+	if !strings.Contains(got, "theirs") || !strings.Contains(got, "mine") {
+		t.Errorf("SaveMerge after Update should keep both, got %q", got)
+	}
+}
+
+// Given LockTimeout is set and the lock was released after load
+// When a transaction runs
+// Then the cross-process lock is held inside the callback and released afterwards.
+func TestUpdate_HoldsFileLock(t *testing.T) {
+	path := writeHostsFile(t, testHostsLocalhost)
+
+	hosts, err := NewHosts(&HostsConfig{ReadFilePath: path, LockTimeout: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := hosts.ReleaseLock(); err != nil {
+		t.Fatal(err)
+	}
+
+	err = hosts.Update(func(tx *Tx) error {
+		if _, err := LockHostsFile(path, 0); !errors.Is(err, ErrLocked) {
+			t.Errorf("lock should be held inside Update, got %v", err)
+		}
+		tx.AddHost(testIPv4Alt, "locked")
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := LockHostsFile(path, 0)
+	if err != nil {
+		t.Fatalf("lock should be free after Update, got %v", err)
+	}
+	_ = l.Unlock()
+}
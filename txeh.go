@@ -4,15 +4,16 @@
 package txeh
 
 import (
-	"errors"
 	"fmt"
-	"net"
+	"io"
+	"net/netip"
 	"os"
 	"path/filepath"
-	"regexp"
 	"runtime"
+	"slices"
 	"strings"
 	"sync"
+	"time"
 )
 
 // Line type constants for HostFileLine.
@@ -46,6 +47,17 @@ type HostsConfig struct {
 	// RawText for input. If RawText is set ReadFilePath, WriteFilePath are ignored. Use RenderHostsFile rather
 	// than save to get the results.
 	RawText *string
+	// Reader, when set and RawText is nil, is read to the end by NewHosts as
+	// the hosts file content, e.g. os.Stdin or a gzip.Reader. As with RawText,
+	// ReadFilePath and WriteFilePath are ignored; use WriteTo or
+	// RenderHostsFile to get the results.
+	Reader io.Reader
+	// Store, when set and RawText and Reader are nil, is the backend NewHosts
+	// and Reload load from and Save writes to, in place of ReadFilePath and
+	// WriteFilePath. A *FileStore sets those paths and behaves exactly like
+	// them. LockTimeout, Backup and the concurrent modification check only
+	// apply to files.
+	Store Store
 	// MaxHostsPerLine limits the number of hostnames per line when adding hosts.
 	// This is useful for Windows which has a limitation of ~9 hostnames per line.
 	// Values:
@@ -56,6 +68,51 @@ type HostsConfig struct {
 	// AutoFlush triggers a DNS cache flush after every successful Save/SaveAs.
 	// Flush failures are returned as *FlushError, distinguishable via errors.As.
 	AutoFlush bool
+	// Flusher is what AutoFlush runs. Nil means SystemFlusher. Use a
+	// ChainFlusher to also flush nscd, dnsmasq or unbound.
+	Flusher Flusher
+	// LockTimeout enables cross-process locking of the hosts file. When greater
+	// than zero, NewHosts and Reload take an advisory lock on the write path
+	// (waiting up to LockTimeout) before reading, and hold it until the next
+	// Save or SaveAs of that path completes. Writes made without a held lock
+	// take it for the duration of the write. A lock that cannot be acquired in
+	// time produces an error wrapping ErrLocked. Zero disables locking.
+	LockTimeout time.Duration
+	// Backup copies the current hosts file to a timestamped backup
+	// (e.g. /etc/hosts.txeh-20261016120000) before every write.
+	Backup bool
+	// BackupDir stores backups in this directory instead of next to the hosts file.
+	BackupDir string
+	// BackupRetention is the number of backups kept; older ones are removed.
+	// Values:
+	//   0  = DefaultBackupRetention
+	//  -1  = keep all backups
+	//  >0  = explicit count
+	BackupRetention int
+	// PreserveFormatting writes address lines that no mutation touched back
+	// byte for byte (spacing, alignment and hostname case included). Only new
+	// or changed lines are reformatted, so loading and saving an unchanged
+	// file produces no diff.
+	PreserveFormatting bool
+	// LineEnding selects the line terminator of the rendered file. The default,
+	// LineEndingAuto, reproduces the input's style, and also keeps a UTF-8 BOM
+	// and a missing final newline as found. LineEndingLF and LineEndingCRLF
+	// force a terminator.
+	LineEnding LineEnding
+	// AddressPolicy decides what adding a hostname does when it is already
+	// mapped to a different address of the same IP family. The default,
+	// AddressPolicyMove, moves it (keeping it where it is when the new address
+	// is a loopback address). Add methods without an error result skip hosts
+	// rejected by AddressPolicyReject; use AddHostWithOptions to see the error.
+	AddressPolicy AddressPolicy
+	// SaveInterval, when greater than zero, coalesces saves for long-lived
+	// instances that change in bursts. Save then marks the hosts dirty and
+	// returns; a background write follows at most once per SaveInterval, and
+	// AutoFlush runs once, after SaveInterval passes without another Save.
+	// Call Sync to write pending changes immediately and Close before
+	// discarding the instance. SaveAs, SaveMerge and transactions still
+	// write immediately, which also covers a pending background write.
+	SaveInterval time.Duration
 }
 
 // Hosts represents a parsed hosts file with thread-safe operations.
@@ -63,6 +120,18 @@ type Hosts struct {
 	mu sync.Mutex
 	*HostsConfig
 	hostFileLines HostFileLines
+	fileLock      *FileLock
+	// loaded describes the file content hostFileLines was parsed from, and
+	// pending holds the mutations applied since then (see SaveMerge).
+	loaded  *fileSnapshot
+	pending []func(*Hosts)
+	// format is the line ending, BOM and final newline state of the input.
+	format fileFormat
+	// index locates hostnames and addresses in hostFileLines, or is nil
+	// until first needed (see indexLocked).
+	index *lineIndex
+	// coalesce tracks saves deferred by SaveInterval.
+	coalesce coalescer
 }
 
 // AddressLocations maps an address to its location in the HFL.
@@ -78,12 +147,21 @@ type HostFileLines []HostFileLine
 type HostFileLine struct {
 	OriginalLineNum int
 	LineType        int
-	Address         string
-	Parts           []string
-	Hostnames       []string
-	Raw             string
-	Trimmed         string
-	Comment         string
+	// Address is the address as written in the file.
+	Address   string
+	Parts     []string
+	Hostnames []string
+	Raw       string
+	Trimmed   string
+	Comment   string
+	// Tags holds the key=value tokens of Comment (see ParseTags), or nil.
+	Tags map[string]string
+	// pristine is set on address lines parsed from input and cleared when a
+	// mutation changes them. With PreserveFormatting, pristine lines render as Raw.
+	pristine bool
+	// addr is Address in canonical form (see parseAddr), or the zero Addr
+	// when it does not parse. Lines are matched on addr.
+	addr netip.Addr
 }
 
 // NewHostsDefault returns a hosts object with default configuration.
@@ -97,117 +175,223 @@ func NewHosts(hc *HostsConfig) (*Hosts, error) {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
-	defaultHostsFile := "/etc/hosts"
+	defaultHostsFile := systemHostsFile()
 
-	if runtime.GOOS == osWindows {
-		defaultHostsFile = winDefaultHostsFile()
+	if fileStore, ok := h.Store.(*FileStore); ok && h.inMemorySource() == "" {
+		h.ReadFilePath = fileStore.Path
+		h.WriteFilePath = fileStore.writePath()
 	}
 
-	if h.ReadFilePath == "" && h.RawText == nil {
+	if h.ReadFilePath == "" && h.nonFileSource() == "" {
 		h.ReadFilePath = defaultHostsFile
 	}
 
-	if h.WriteFilePath == "" && h.RawText == nil {
+	if h.WriteFilePath == "" && h.nonFileSource() == "" {
 		h.WriteFilePath = h.ReadFilePath
 	}
 
 	if h.RawText != nil {
-		hfl, err := ParseHostsFromString(*h.RawText)
-		if err != nil {
+		if err := h.parseLocked(strings.NewReader(*h.RawText)); err != nil {
 			return nil, err
 		}
 
-		h.hostFileLines = hfl
 		return h, nil
 	}
 
-	hfl, err := ParseHosts(h.ReadFilePath)
-	if err != nil {
-		return nil, err
+	if h.Reader != nil {
+		if err := h.parseLocked(h.Reader); err != nil {
+			return nil, err
+		}
+
+		return h, nil
 	}
 
-	h.hostFileLines = hfl
+	if h.LockTimeout > 0 && h.customStore() == nil {
+		if err := h.acquireLockLocked(h.LockTimeout); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := h.loadLocked(); err != nil {
+		_ = h.releaseLockLocked()
+		return nil, err
+	}
 
 	return h, nil
 }
 
-// Save renders and writes the hosts file to the configured write path.
+// Save renders and writes the hosts file to the configured write path, or
+// to the configured Store. With SaveInterval set, Save only schedules the
+// write (see SaveInterval) and returns the error of an earlier background
+// write, if any.
 func (h *Hosts) Save() error {
-	return h.SaveAs(h.WriteFilePath)
+	if src := h.inMemorySource(); src != "" {
+		return fmt.Errorf("cannot call Save or SaveAs with %s. Use RenderHostsFile to return a string", src)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.SaveInterval > 0 && !h.coalesce.closed {
+		return h.deferSaveLocked()
+	}
+	return h.saveLocked()
+}
+
+// saveLocked writes the hosts file to the configured write path or Store.
+// Must be called with the lock held.
+func (h *Hosts) saveLocked() error {
+	if s := h.customStore(); s != nil {
+		return h.saveToStoreLocked(s)
+	}
+
+	return h.saveAsLocked(h.WriteFilePath)
 }
 
 // SaveAs saves rendered hosts file to the filename specified.
+//
+// The write is atomic: the content goes to a temporary file in the same
+// directory, is synced to disk and then renamed over the target, so readers
+// never observe a truncated hosts file. Symlinks are followed and the real file
+// is replaced; its mode, owner and group carry over to the new file.
+//
+// When fileName is the file this instance was loaded from and that file has
+// been changed on disk since NewHosts or Reload, SaveAs returns an error
+// wrapping ErrConcurrentModification and writes nothing. Use SaveMerge to apply
+// this instance's changes on top of the current file instead.
 func (h *Hosts) SaveAs(fileName string) error {
-	if h.RawText != nil {
-		return errors.New("cannot call Save or SaveAs with RawText. Use RenderHostsFile to return a string")
+	if src := h.inMemorySource(); src != "" {
+		return fmt.Errorf("cannot call Save or SaveAs with %s. Use RenderHostsFile to return a string", src)
 	}
-	hfData := []byte(h.RenderHostsFile())
 
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
-	err := os.WriteFile(filepath.Clean(fileName), hfData, 0o644) // #nosec G306 -- hosts file must be world-readable (0644) for DNS resolution
+	return h.saveAsLocked(fileName)
+}
+
+// saveAsLocked is SaveAs with the lock held.
+func (h *Hosts) saveAsLocked(fileName string) error {
+	release, err := h.lockForWriteLocked(fileName)
 	if err != nil {
-		return fmt.Errorf("write hosts file %s: %w", fileName, err)
+		return err
 	}
+	defer func() { _ = release() }()
 
-	if h.AutoFlush {
-		if flushErr := FlushDNSCache(); flushErr != nil {
-			return flushErr
+	if err := h.checkUnmodifiedLocked(fileName); err != nil {
+		return err
+	}
+
+	return h.writeLocked(fileName)
+}
+
+// writeLocked renders and atomically writes the hosts file to fileName, then
+// runs the configured auto flush. Must be called with the lock held.
+func (h *Hosts) writeLocked(fileName string) error {
+	return h.writeDataLocked(fileName, []byte(h.renderLocked()))
+}
+
+// writeDataLocked backs up fileName when configured, atomically replaces it
+// with hfData and runs the configured auto flush. Must be called with the lock held.
+func (h *Hosts) writeDataLocked(fileName string, hfData []byte) error {
+	if h.Backup {
+		if err := h.backupLocked(fileName); err != nil {
+			return err
 		}
 	}
 
-	return nil
+	err := writeFileAtomic(fileName, hfData)
+	if err != nil {
+		return fmt.Errorf("write hosts file %s: %w", fileName, err)
+	}
+	h.recordWriteLocked(fileName, hfData)
+	if sameFile(fileName, h.WriteFilePath) {
+		h.coalescedWrittenLocked()
+	}
+
+	return h.autoFlushLocked()
 }
 
-// Reload re-reads the hosts file from disk and replaces the in-memory state.
+// Reload re-reads the hosts file from disk (or the configured Store) and
+// replaces the in-memory state.
 // This is part of the public API for consumers who manage long-lived Hosts instances.
+// With LockTimeout set, the cross-process lock is taken before reading and held
+// until the next Save.
 func (h *Hosts) Reload() error {
-	if h.RawText != nil {
-		return errors.New("cannot call Reload with RawText")
+	if src := h.inMemorySource(); src != "" {
+		return fmt.Errorf("cannot call Reload with %s", src)
 	}
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
-	hfl, err := ParseHosts(h.ReadFilePath)
-	if err != nil {
-		return err
+	if h.LockTimeout > 0 && h.customStore() == nil {
+		if err := h.acquireLockLocked(h.LockTimeout); err != nil {
+			return err
+		}
 	}
 
-	h.hostFileLines = hfl
+	if err := h.loadLocked(); err != nil {
+		_ = h.releaseLockLocked()
+		return err
+	}
 
 	return nil
 }
 
 // RemoveAddresses removes all entries (lines) with the provided address.
 func (h *Hosts) RemoveAddresses(addresses []string) {
+	matchers := make([]addressMatcher, 0, len(addresses))
 	for _, address := range addresses {
-		if h.RemoveFirstAddress(address) {
-			h.RemoveAddress(address)
-		}
+		matchers = append(matchers, newAddressMatcher(address))
 	}
+	h.mutate(func(x *Hosts) { x.removeAddressesLocked(matchers) })
 }
 
 // RemoveAddress removes all entries (lines) with the provided address.
 func (h *Hosts) RemoveAddress(address string) {
-	if h.RemoveFirstAddress(address) {
-		h.RemoveAddress(address)
-	}
+	h.RemoveAddresses([]string{address})
 }
 
 // RemoveFirstAddress removes the first entry (line) found with the provided address.
 func (h *Hosts) RemoveFirstAddress(address string) bool {
-	h.mu.Lock()
-	defer h.mu.Unlock()
+	var removed bool
+	h.mutate(func(x *Hosts) { removed = x.removeFirstAddressLocked(address) })
+	return removed
+}
+
+// removeAddressesLocked removes all lines matching any of matchers in one
+// pass. Must be called with the lock held.
+func (h *Hosts) removeAddressesLocked(matchers []addressMatcher) {
+	addrs := make(map[netip.Addr]bool, len(matchers))
+	var texts []addressMatcher
+	for _, m := range matchers {
+		if m.addr.IsValid() {
+			addrs[m.addr] = true
+		} else {
+			texts = append(texts, m)
+		}
+	}
 
-	for hflIdx := range h.hostFileLines {
-		if address == h.hostFileLines[hflIdx].Address {
-			h.hostFileLines = removeHFLElement(h.hostFileLines, hflIdx)
-			return true
+	h.deleteLinesLocked(func(hfl *HostFileLine) bool {
+		if hfl.addr.IsValid() {
+			return addrs[hfl.addr]
 		}
+		return slices.ContainsFunc(texts, func(m addressMatcher) bool { return m.matches(hfl) })
+	})
+}
+
+// removeFirstAddressLocked removes the first line with the provided address.
+// Must be called with the lock held.
+func (h *Hosts) removeFirstAddressLocked(address string) bool {
+	positions := h.addressLines(newAddressMatcher(address))
+	if len(positions) == 0 {
+		return false
 	}
 
-	return false
+	h.hostFileLines = slices.Delete(h.hostFileLines, positions[0], positions[0]+1)
+	h.invalidateIndexLocked()
+
+	return true
 }
 
 // RemoveCIDRs Remove CIDR Range (Classless inter-domain routing)
@@ -216,104 +400,110 @@ func (h *Hosts) RemoveFirstAddress(address string) bool {
 //	127.1.0.0/16  = 127.1.0.0  -> 127.1.255.255
 //	127.1.27.0/24 = 127.1.27.0 -> 127.1.27.255
 func (h *Hosts) RemoveCIDRs(cidrs []string) error {
-	addresses := make([]string, 0)
-
-	// loop through all the CIDR ranges (we probably have less ranges than IPs)
+	prefixes := make([]netip.Prefix, 0, len(cidrs))
 	for _, cidr := range cidrs {
-		_, ipnet, err := net.ParseCIDR(cidr)
+		prefix, err := netip.ParsePrefix(cidr)
 		if err != nil {
 			return fmt.Errorf("parse CIDR %s: %w", cidr, err)
 		}
-
-		hfLines := h.GetHostFileLines()
-
-		for _, hfl := range hfLines {
-			ip := net.ParseIP(hfl.Address)
-			if ip != nil {
-				if ipnet.Contains(ip) {
-					addresses = append(addresses, hfl.Address)
-				}
-			}
-		}
+		prefixes = append(prefixes, prefix)
 	}
 
-	h.RemoveAddresses(addresses)
+	h.mutate(func(x *Hosts) { x.removeCIDRsLocked(prefixes) })
 
 	return nil
 }
 
+// removeCIDRsLocked removes all lines whose address falls in any of the ranges.
+// Must be called with the lock held.
+func (h *Hosts) removeCIDRsLocked(prefixes []netip.Prefix) {
+	h.deleteLinesLocked(func(hfl *HostFileLine) bool {
+		return slices.ContainsFunc(prefixes, func(p netip.Prefix) bool { return prefixContains(p, hfl.addr) })
+	})
+}
+
 // RemoveHosts removes all hostname entries of the provided host slice.
 func (h *Hosts) RemoveHosts(hosts []string) {
-	for _, host := range hosts {
-		if h.RemoveFirstHost(host) {
-			h.RemoveHost(host)
-		}
-	}
+	hosts = slices.Clone(hosts)
+	h.mutate(func(x *Hosts) { x.removeHostsLocked(hosts) })
 }
 
-// RemoveHost removes all hostname entries of provided host.
+// RemoveHost removes all hostname entries of provided host. An
+// internationalized name also matches its punycode form.
 func (h *Hosts) RemoveHost(host string) {
-	if h.RemoveFirstHost(host) {
-		h.RemoveHost(host)
-	}
+	h.RemoveHosts([]string{host})
 }
 
 // RemoveFirstHost removes the first hostname entry found and returns true if successful.
 func (h *Hosts) RemoveFirstHost(host string) bool {
-	host = strings.TrimSpace(strings.ToLower(host))
-	h.mu.Lock()
-	defer h.mu.Unlock()
+	var removed bool
+	h.mutate(func(x *Hosts) { removed = x.removeFirstHostLocked(host) })
+	return removed
+}
 
-	for hflIdx := range h.hostFileLines {
-		for hidx, hst := range h.hostFileLines[hflIdx].Hostnames {
-			if hst == host {
-				h.hostFileLines[hflIdx].Hostnames = removeStringElement(h.hostFileLines[hflIdx].Hostnames, hidx)
+// removeHostsLocked removes every occurrence of hosts in one pass.
+// Must be called with the lock held.
+func (h *Hosts) removeHostsLocked(hosts []string) {
+	var positions []int
+	names := make([]string, 0, 2*len(hosts))
+	for _, host := range hosts {
+		host, lower := hostnameForms(host)
+		positions = append(positions, h.hostLines(host, lower)...)
+		names = append(names, host, lower)
+	}
+	slices.Sort(positions)
 
-				// remove the address line if empty
-				if len(h.hostFileLines[hflIdx].Hostnames) < 1 {
-					h.hostFileLines = removeHFLElement(h.hostFileLines, hflIdx)
-				}
-				return true
-			}
-		}
+	h.removeHostsAtLocked(slices.Compact(positions), names...)
+}
+
+// removeFirstHostLocked removes the first occurrence of host and reports
+// whether one was found. Must be called with the lock held.
+func (h *Hosts) removeFirstHostLocked(host string) bool {
+	host, lower := hostnameForms(host)
+	positions := h.hostLines(host, lower)
+	if len(positions) == 0 {
+		return false
 	}
 
-	return false
+	i := positions[0]
+	hidx := slices.IndexFunc(h.hostFileLines[i].Hostnames, func(hn string) bool { return hn == host || hn == lower })
+	h.deleteHostnameLocked(i, hidx)
+
+	return true
 }
 
 // RemoveByComments removes all host entries that have any of the specified comments.
 // This removes entire lines where the comment matches.
 // This is part of the public API for bulk comment-based cleanup (e.g., kubefwd teardown).
 func (h *Hosts) RemoveByComments(comments []string) {
+	set := make(map[string]bool, len(comments))
 	for _, comment := range comments {
-		h.RemoveByComment(comment)
+		set[strings.TrimSpace(comment)] = true
 	}
+	h.mutate(func(x *Hosts) { x.removeByCommentsLocked(set) })
 }
 
 // RemoveByComment removes all host entries that have the specified comment.
 // This removes entire lines where the comment matches.
 func (h *Hosts) RemoveByComment(comment string) {
-	h.mu.Lock()
-	defer h.mu.Unlock()
-
-	comment = strings.TrimSpace(comment)
-	var newLines HostFileLines
-
-	for _, hfl := range h.hostFileLines {
-		if hfl.Comment != comment {
-			newLines = append(newLines, hfl)
-		}
-	}
+	h.RemoveByComments([]string{comment})
+}
 
-	h.hostFileLines = newLines
+// removeByCommentsLocked removes all lines whose comment is in comments.
+// Must be called with the lock held.
+func (h *Hosts) removeByCommentsLocked(comments map[string]bool) {
+	h.deleteLinesLocked(func(hfl *HostFileLine) bool { return comments[hfl.Comment] })
 }
 
 // AddHosts adds an array of hosts to the first matching address it finds
 // or creates the address and adds the hosts.
 func (h *Hosts) AddHosts(address string, hosts []string) {
-	for _, hst := range hosts {
-		h.AddHost(address, hst)
-	}
+	hosts = slices.Clone(hosts)
+	h.mutate(func(x *Hosts) {
+		for _, hst := range hosts {
+			x.addHostWithCommentLocked(address, hst, "")
+		}
+	})
 }
 
 // AddHostsWithComment adds an array of hosts to an address with a comment.
@@ -321,15 +511,19 @@ func (h *Hosts) AddHosts(address string, hosts []string) {
 // the same comment, hosts are appended to that line (respecting MaxHostsPerLine).
 // If the address exists with a different comment, a new line is created.
 func (h *Hosts) AddHostsWithComment(address string, hosts []string, comment string) {
-	for _, hst := range hosts {
-		h.AddHostWithComment(address, hst, comment)
-	}
+	hosts = slices.Clone(hosts)
+	h.mutate(func(x *Hosts) {
+		for _, hst := range hosts {
+			x.addHostWithCommentLocked(address, hst, comment)
+		}
+	})
 }
 
 // AddHost adds a host to an address and removes the host
-// from any existing address it may be associated with.
+// from any existing address it may be associated with. Internationalized
+// hostnames are stored in punycode form (see NormalizeHostname).
 func (h *Hosts) AddHost(addressRaw, hostRaw string) {
-	h.addHostWithComment(addressRaw, hostRaw, "")
+	h.mutate(func(x *Hosts) { x.addHostWithCommentLocked(addressRaw, hostRaw, "") })
 }
 
 // AddHostWithComment adds a host to an address with an inline comment.
@@ -338,54 +532,77 @@ func (h *Hosts) AddHost(addressRaw, hostRaw string) {
 // (respecting MaxHostsPerLine). If the address exists with a different comment, a new line
 // is created with the specified comment.
 func (h *Hosts) AddHostWithComment(addressRaw, hostRaw, comment string) {
-	h.addHostWithComment(addressRaw, hostRaw, comment)
+	h.mutate(func(x *Hosts) { x.addHostWithCommentLocked(addressRaw, hostRaw, comment) })
 }
 
-// addHostWithComment is the internal implementation that handles both
-// commented and non-commented host additions.
-func (h *Hosts) addHostWithComment(addressRaw, hostRaw, comment string) { //nolint:revive // internal method mirrors public API naming
-	host := stripLineBreaks(strings.TrimSpace(strings.ToLower(hostRaw)))
-	address := strings.TrimSpace(strings.ToLower(addressRaw))
+// addHostWithCommentLocked is the internal implementation that handles both
+// commented and non-commented host additions with the configured address
+// policy. A host the policy rejects is skipped. Must be called with the lock held.
+func (h *Hosts) addHostWithCommentLocked(addressRaw, hostRaw, comment string) {
+	_ = h.addHostLocked(addressRaw, hostRaw, comment, h.addressPolicy(AddressPolicyDefault))
+}
+
+// addHostLocked adds a host to an address, resolving a mapping to another
+// address of the same family according to policy (which must not be
+// AddressPolicyDefault). With AddressPolicyReject such a host is not added and
+// an error wrapping ErrAddressConflict is returned. Must be called with the
+// lock held.
+func (h *Hosts) addHostLocked(addressRaw, hostRaw, comment string, policy AddressPolicy) error {
+	host := canonicalHostname(hostRaw)
+	address := strings.TrimSpace(addressRaw)
 	// Normalize comment: trim spaces, but don't add/remove the # prefix
 	// (the # is handled during rendering). Strip line-break characters so a
 	// single logical entry cannot be split into multiple physical lines when
 	// rendered (CWE-117). See stripLineBreaks for details.
 	comment = stripLineBreaks(strings.TrimSpace(comment))
 
-	addressIP := net.ParseIP(address)
-	if addressIP == nil {
-		return
+	addr, ok := parseAddr(address)
+	if !ok {
+		return nil
 	}
 	ipFamily := IPFamilyV4
-	if addressIP.To4() == nil {
+	if !addr.Is4() {
 		ipFamily = IPFamilyV6
 	}
 
-	h.mu.Lock()
-	defer h.mu.Unlock()
-
 	// does the host already exist
 	ok, exAdd, hflIdx := h.hostAddressLookupLocked(host, ipFamily)
 	if ok {
-		if address == exAdd {
-			return // already at correct address
+		if h.hostFileLines[hflIdx].addr == addr {
+			return nil // already at correct address
+		}
+		switch policy {
+		case AddressPolicyReject:
+			return addressConflictError(host, exAdd)
+		case AddressPolicyMove:
+			// hostname is at other addresses, remove it from all of them
+			h.removeHostFromFamilyLocked(host, addr, address)
+		}
+		// It may still be on a later line for this address (kept for
+		// localhost or AddressPolicyAllowMultiple).
+		if h.hostOnAddressLocked(host, addr) {
+			return nil
 		}
-		// hostname is at a different address, remove it from there
-		h.removeHostFromLineLocked(hflIdx, host, address)
 	}
 
 	// Get the effective max hosts per line limit
 	maxPerLine := h.getEffectiveMaxHostsPerLine()
 
-	// if the address exists with matching comment, add it to that line if there's room
-	for i, hfl := range h.hostFileLines {
-		if hfl.Address == address && hfl.Comment == comment {
-			// Check if this line has room (0 means unlimited)
-			if maxPerLine <= 0 || len(h.hostFileLines[i].Hostnames) < maxPerLine {
-				h.hostFileLines[i].Hostnames = append(h.hostFileLines[i].Hostnames, host)
-				return
-			}
-			// This line is full, continue looking for another line with the same address and comment
+	// if the address exists with matching comment, add it to that line if there's room.
+	// Lines inside managed blocks belong to the block owner and are skipped.
+	idx := h.indexLocked()
+	for _, i := range idx.byAddr[addr] {
+		if idx.inBlock[i] {
+			continue
+		}
+		hfl := &h.hostFileLines[i]
+		// Check if this line has room (0 means unlimited); if it is full,
+		// continue looking for another line with the same address and comment
+		if hfl.Comment == comment && (maxPerLine <= 0 || len(hfl.Hostnames) < maxPerLine) {
+			hfl.Hostnames = append(hfl.Hostnames, host)
+			hfl.pristine = false
+			idx.addHost(i, host)
+			return nil
 		}
 	}
 
@@ -395,9 +612,13 @@ func (h *Hosts) addHostWithComment(addressRaw, hostRaw, comment string) { //noli
 		Address:   address,
 		Hostnames: []string{host},
 		Comment:   comment,
+		Tags:      ParseTags(comment),
+		addr:      addr,
 	}
 
-	h.hostFileLines = append(h.hostFileLines, hfl)
+	h.appendLineLocked(hfl)
+
+	return nil
 }
 
 // ListHostsByIP returns a list of hostnames associated with a given IP address.
@@ -406,29 +627,41 @@ func (h *Hosts) ListHostsByIP(address string) []string {
 	defer h.mu.Unlock()
 
 	var hosts []string
-
-	for _, hsl := range h.hostFileLines {
-		if hsl.Address == address {
-			hosts = append(hosts, hsl.Hostnames...)
-		}
+	for _, i := range h.addressLines(newAddressMatcher(address)) {
+		hosts = append(hosts, h.hostFileLines[i].Hostnames...)
 	}
 
 	return hosts
 }
 
 // ListAddressesByHost returns a list of IPs associated with a given hostname.
+// An internationalized name also matches its punycode form.
 func (h *Hosts) ListAddressesByHost(hostname string, exact bool) [][]string {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
 	var addresses [][]string
+	ascii := canonicalHostname(hostname)
+
+	if exact {
+		for _, i := range h.hostLines(ascii, hostname) {
+			hsl := &h.hostFileLines[i]
+			for _, hst := range hsl.Hostnames {
+				if hst == hostname || hst == ascii {
+					addresses = append(addresses, []string{hsl.Address, hst})
+				}
+			}
+		}
+		return addresses
+	}
 
 	for _, hsl := range h.hostFileLines {
 		for _, hst := range hsl.Hostnames {
-			if hst == hostname {
+			match := hst == hostname || hst == ascii
+			if match {
 				addresses = append(addresses, []string{hsl.Address, hst})
 			}
-			if !exact && hst != hostname && strings.Contains(hst, hostname) {
+			if !exact && !match && (strings.Contains(hst, hostname) || strings.Contains(hst, ascii)) {
 				addresses = append(addresses, []string{hsl.Address, hst})
 			}
 		}
@@ -444,13 +677,13 @@ func (h *Hosts) ListHostsByCIDR(cidr string) [][]string {
 
 	var ipHosts [][]string
 
-	_, subnet, err := net.ParseCIDR(cidr)
+	prefix, err := netip.ParsePrefix(cidr)
 	if err != nil {
 		return ipHosts
 	}
 
 	for _, hsl := range h.hostFileLines {
-		if subnet.Contains(net.ParseIP(hsl.Address)) {
+		if prefixContains(prefix, hsl.addr) {
 			for _, hst := range hsl.Hostnames {
 				ipHosts = append(ipHosts, []string{hsl.Address, hst})
 			}
@@ -479,7 +712,8 @@ func (h *Hosts) ListHostsByComment(comment string) []string {
 
 // HostAddressLookup returns true if the host is found, the address string,
 // and the index of the host file line. This is part of the public API for
-// consumers that need direct address lookups by IP family.
+// consumers that need direct address lookups by IP family. An
+// internationalized name also matches its punycode form.
 func (h *Hosts) HostAddressLookup(host string, ipFamily IPFamily) (found bool, address string, idx int) {
 	h.mu.Lock()
 	defer h.mu.Unlock()
@@ -489,20 +723,12 @@ func (h *Hosts) HostAddressLookup(host string, ipFamily IPFamily) (found bool, a
 
 // hostAddressLookupLocked is the internal version that assumes the lock is already held.
 func (h *Hosts) hostAddressLookupLocked(host string, ipFamily IPFamily) (found bool, address string, idx int) {
-	host = strings.ToLower(strings.TrimSpace(host))
+	host, lower := hostnameForms(host)
 
-	for i, hfl := range h.hostFileLines {
-		for _, hn := range hfl.Hostnames {
-			ipAddr := net.ParseIP(hfl.Address)
-			if ipAddr == nil || hn != host {
-				continue
-			}
-			if ipFamily == IPFamilyV4 && ipAddr.To4() != nil {
-				return true, hfl.Address, i
-			}
-			if ipFamily == IPFamilyV6 && ipAddr.To4() == nil {
-				return true, hfl.Address, i
-			}
+	for _, i := range h.hostLines(host, lower) {
+		hfl := &h.hostFileLines[i]
+		if hfl.addr.IsValid() && hfl.addr.Is4() == (ipFamily == IPFamilyV4) {
+			return true, hfl.Address, i
 		}
 	}
 
@@ -514,13 +740,43 @@ func (h *Hosts) RenderHostsFile() string {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
+	return h.renderLocked()
+}
+
+// renderLocked renders the hosts file. Must be called with the lock held.
+func (h *Hosts) renderLocked() string {
 	var sb strings.Builder
-	for _, hfl := range h.hostFileLines {
-		sb.WriteString(lineFormatter(hfl))
-		sb.WriteByte('\n')
+	_ = h.renderToLocked(&sb)
+	return sb.String()
+}
+
+// renderToLocked renders the hosts file to w one line at a time. Must be
+// called with the lock held.
+func (h *Hosts) renderToLocked(w io.StringWriter) error {
+	preserve := h.HostsConfig != nil && h.PreserveFormatting
+	eol := h.lineTerminator()
+
+	if h.format.bom {
+		if _, err := w.WriteString(utf8BOM); err != nil {
+			return err
+		}
+	}
+	for i, hfl := range h.hostFileLines {
+		line := hfl.Raw
+		if !preserve || !hfl.pristine {
+			line = lineFormatter(hfl)
+		}
+		if _, err := w.WriteString(line); err != nil {
+			return err
+		}
+		if i < len(h.hostFileLines)-1 || h.format.finalNewline {
+			if _, err := w.WriteString(eol); err != nil {
+				return err
+			}
+		}
 	}
 
-	return sb.String()
+	return nil
 }
 
 // GetHostFileLines returns a copy of all parsed host file lines.
@@ -564,100 +820,92 @@ func (h *Hosts) getEffectiveMaxHostsPerLine() int {
 
 // ParseHosts reads and parses a hosts file from the given path.
 func ParseHosts(path string) ([]HostFileLine, error) {
-	input, err := os.ReadFile(filepath.Clean(path))
+	f, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return nil, fmt.Errorf("read hosts file %s: %w", path, err)
+	}
+	defer func() { _ = f.Close() }()
+
+	hostFileLines, _, err := readHosts(f)
 	if err != nil {
 		return nil, fmt.Errorf("read hosts file %s: %w", path, err)
 	}
-	return ParseHostsFromString(string(input))
+	return hostFileLines, nil
 }
 
 // ParseHostsFromString parses hosts file content from a string.
 func ParseHostsFromString(input string) ([]HostFileLine, error) {
-	inputNormalized := strings.ReplaceAll(input, "\r\n", "\n")
-
-	dataLines := strings.Split(inputNormalized, "\n")
-	// remove extra blank line at end that does not exist in /etc/hosts file
-	// only if the file ended with a newline (last element is empty)
-	if len(dataLines) > 0 && dataLines[len(dataLines)-1] == "" {
-		dataLines = dataLines[:len(dataLines)-1]
-	}
-
-	hostFileLines := make([]HostFileLine, len(dataLines))
-
-	// trim leading and trailing whitespace
-	for i, l := range dataLines {
-		curLine := &hostFileLines[i]
-		curLine.OriginalLineNum = i
-		curLine.Raw = l
+	return ParseHostsReader(strings.NewReader(input))
+}
 
-		// trim line
-		curLine.Trimmed = strings.TrimSpace(l)
+// parseHostsLine parses line l, the num'th (0-based) line of a hosts file,
+// without its line terminator.
+func parseHostsLine(num int, l string) HostFileLine {
+	curLine := HostFileLine{OriginalLineNum: num, Raw: l}
 
-		// check for comment
-		if strings.HasPrefix(curLine.Trimmed, "#") {
-			curLine.LineType = COMMENT
-			continue
-		}
+	// trim line
+	curLine.Trimmed = strings.TrimSpace(l)
 
-		if curLine.Trimmed == "" {
-			curLine.LineType = EMPTY
-			continue
-		}
+	// check for comment
+	if strings.HasPrefix(curLine.Trimmed, "#") {
+		curLine.LineType = COMMENT
+		return curLine
+	}
 
-		curLineSplit := strings.SplitN(curLine.Trimmed, "#", 2)
-		if len(curLineSplit) > 1 {
-			curLine.Comment = strings.TrimSpace(curLineSplit[1])
-		}
-		curLine.Trimmed = curLineSplit[0]
+	if curLine.Trimmed == "" {
+		curLine.LineType = EMPTY
+		return curLine
+	}
 
-		curLine.Parts = strings.Fields(curLine.Trimmed)
+	curLineSplit := strings.SplitN(curLine.Trimmed, "#", 2)
+	if len(curLineSplit) > 1 {
+		curLine.Comment = strings.TrimSpace(curLineSplit[1])
+		curLine.Tags = ParseTags(curLine.Comment)
+	}
+	curLine.Trimmed = curLineSplit[0]
 
-		if len(curLine.Parts) > 1 {
-			curLine.LineType = ADDRESS
-			curLine.Address = strings.ToLower(curLine.Parts[0])
-			// lower case all
-			for _, p := range curLine.Parts[1:] {
-				curLine.Hostnames = append(curLine.Hostnames, strings.ToLower(p))
-			}
+	curLine.Parts = strings.Fields(curLine.Trimmed)
 
-			continue
+	if len(curLine.Parts) > 1 {
+		curLine.LineType = ADDRESS
+		curLine.pristine = true
+		curLine.Address = curLine.Parts[0]
+		curLine.addr, _ = parseAddr(curLine.Address)
+		// lower case all
+		for _, p := range curLine.Parts[1:] {
+			curLine.Hostnames = append(curLine.Hostnames, strings.ToLower(p))
 		}
 
-		// if we can't figure out what this line is
-		// at this point mark it as unknown
-		curLine.LineType = UNKNOWN
+		return curLine
 	}
 
-	return hostFileLines, nil
-}
-
-// removeStringElement removes an element of a string slice.
-func removeStringElement(slice []string, s int) []string {
-	return append(slice[:s], slice[s+1:]...)
-}
-
-// removeHFLElement removes an element of a HostFileLine slice.
-func removeHFLElement(slice []HostFileLine, s int) []HostFileLine {
-	return append(slice[:s], slice[s+1:]...)
+	// if we can't figure out what this line is
+	// at this point mark it as unknown
+	curLine.LineType = UNKNOWN
+	return curLine
 }
 
-// removeHostFromLineLocked removes a hostname from a specific line and cleans up empty lines.
+// removeHostFromFamilyLocked removes a hostname from every line of addr's
+// family with a different address and cleans up empty lines.
 // Must be called with lock held. For localhost addresses, this is a no-op since
 // the same hostname can exist at multiple localhost addresses.
-func (h *Hosts) removeHostFromLineLocked(hflIdx int, host, newAddress string) {
+func (h *Hosts) removeHostFromFamilyLocked(host string, addr netip.Addr, newAddress string) {
 	if isLocalhost(newAddress) {
 		return
 	}
 
-	for hidx, hst := range h.hostFileLines[hflIdx].Hostnames {
-		if hst != host {
+	// Lines inside managed blocks belong to the block owner and are kept.
+	idx := h.indexLocked()
+	positions := slices.DeleteFunc(slices.Clone(h.hostLines(host, host)), func(i int) bool { return idx.inBlock[i] })
+	// Walk backwards so deleting an emptied line keeps earlier positions valid.
+	for _, i := range slices.Backward(positions) {
+		hfl := &h.hostFileLines[i]
+		if !hfl.addr.IsValid() || hfl.addr == addr || hfl.addr.Is4() != addr.Is4() {
 			continue
 		}
-		h.hostFileLines[hflIdx].Hostnames = removeStringElement(h.hostFileLines[hflIdx].Hostnames, hidx)
-		if len(h.hostFileLines[hflIdx].Hostnames) == 0 {
-			h.hostFileLines = removeHFLElement(h.hostFileLines, hflIdx)
+		if hidx := slices.Index(hfl.Hostnames, host); hidx >= 0 {
+			h.deleteHostnameLocked(i, hidx)
 		}
-		return
 	}
 }
 
@@ -699,13 +947,12 @@ func lineFormatter(hfl HostFileLine) string {
 	return fmt.Sprintf("%-16s %s", hfl.Address, strings.Join(hostnames, " "))
 }
 
-// ipLocalhost is a regex pattern for IPv4 or IPv6 loopback range.
-const ipLocalhost = `((127\.(\d{1,3}\.){2}\d{1,3})|(::1)$)`
-
-var localhostIPRegexp = regexp.MustCompile(ipLocalhost)
-
-func isLocalhost(address string) bool {
-	return localhostIPRegexp.MatchString(address)
+// systemHostsFile returns the hosts file the operating system reads.
+func systemHostsFile() string {
+	if runtime.GOOS == osWindows {
+		return winDefaultHostsFile()
+	}
+	return "/etc/hosts"
 }
 
 // winDefaultHostsFile returns the default hosts file path for Windows.
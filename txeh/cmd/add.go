@@ -3,16 +3,28 @@ package cmd
 import (
 	"errors"
 	"fmt"
+	"os"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
+
+	"github.com/txn2/txeh"
 )
 
-var addComment string
+var (
+	addComment string
+	addTags    []string
+	addTTL     time.Duration
+	addPolicy  string
+)
 
 func init() {
 	rootCmd.AddCommand(addCmd)
 	addCmd.Flags().StringVarP(&addComment, "comment", "c", "", "Add an inline comment (e.g., 'managed-by-myapp')")
+	addCmd.Flags().StringArrayVarP(&addTags, "tag", "t", nil, "Add a key=value tag to the inline comment (repeatable, e.g. -t owner=myapp -t env=dev)")
+	addCmd.Flags().DurationVar(&addTTL, "ttl", 0, "Expire the entries after this long (e.g. 2h); remove expired entries with \"txeh prune\"")
+	addCmd.Flags().StringVar(&addPolicy, "policy", "", "What to do when a hostname already has another address of the same family: move (default), allow-multiple or reject")
 }
 
 var addCmd = &cobra.Command{
@@ -23,10 +35,24 @@ var addCmd = &cobra.Command{
 Use the --comment flag to add an inline comment that will appear after
 the hostnames on the line (e.g., "127.0.0.1 myhost # my-comment").
 
+Use --tag to record structured key=value metadata in the comment. Tagged
+entries can be listed and removed with "list bytag" and "remove bytag".
+
+Use --ttl for temporary entries. The expiry is stored as an expires tag and
+"txeh prune" (e.g. from cron or a systemd timer) removes expired entries.
+
+A hostname already mapped to another address of the same IP family is moved
+to the new address by default. Use --policy allow-multiple to keep both
+mappings (round-robin setups, a LAN and a VPN address), or --policy reject to
+fail instead.
+
 Examples:
   txeh add 127.0.0.1 myhost
   txeh add 127.0.0.1 myhost --comment "managed-by-myapp"
-  txeh add 127.0.0.1 svc1 svc2 svc3 -c "kubefwd"`,
+  txeh add 127.0.0.1 svc1 svc2 svc3 -c "kubefwd"
+  txeh add 127.0.0.1 svc1 -t owner=kubefwd -t ns=default
+  txeh add 127.0.0.1 feature-x.local --ttl 2h
+  txeh add 10.8.0.5 nas.home --policy allow-multiple`,
 	Args: func(_ *cobra.Command, args []string) error {
 		if len(args) < 2 {
 			return errors.New("the \"add\" command requires an IP address and at least one hostname")
@@ -40,21 +66,66 @@ Examples:
 			return fmt.Errorf("\"%s\" is not a valid hostname", hn)
 		}
 
-		return nil
+		if addTTL < 0 {
+			return errors.New("the --ttl value must be positive")
+		}
+
+		if _, err := txeh.ParseAddressPolicy(addPolicy); err != nil {
+			return err
+		}
+
+		_, err := addTagComment(addComment, addTags)
+		return err
 	},
 	Run: func(_ *cobra.Command, args []string) {
+		comment, _ := addTagComment(addComment, addTags)
+
 		if !Quiet {
-			if addComment != "" {
-				fmt.Printf("Adding host(s) \"%s\" to IP address %s with comment \"%s\"\n", strings.Join(args[1:], " "), args[0], addComment)
+			if comment != "" {
+				fmt.Printf("Adding host(s) \"%s\" to IP address %s with comment \"%s\"\n", strings.Join(args[1:], " "), args[0], comment)
 			} else {
 				fmt.Printf("Adding host(s) \"%s\" to IP address %s\n", strings.Join(args[1:], " "), args[0])
 			}
 		}
 
-		AddHosts(args[0], args[1:], addComment)
+		if addPolicy != "" {
+			policy, _ := txeh.ParseAddressPolicy(addPolicy)
+			AddHostsWithOptions(args[0], args[1:], txeh.AddOptions{Comment: comment, Policy: policy, TTL: addTTL})
+			return
+		}
+
+		if addTTL > 0 {
+			AddHostsWithTTL(args[0], args[1:], comment, addTTL)
+			return
+		}
+
+		AddHosts(args[0], args[1:], comment)
 	},
 }
 
+// addTagComment appends key=value tags to comment.
+func addTagComment(comment string, tags []string) (string, error) {
+	if len(tags) == 0 {
+		return comment, nil
+	}
+
+	m := make(map[string]string, len(tags))
+	for _, t := range tags {
+		k, v, ok := strings.Cut(t, "=")
+		if !ok {
+			return "", fmt.Errorf("tag \"%s\" must be in key=value form", t)
+		}
+		m[k] = v
+	}
+
+	formatted, err := txeh.FormatTags(m)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(comment + " " + formatted), nil
+}
+
 // AddHosts adds hostnames to an IP address with an optional comment.
 func AddHosts(ip string, hosts []string, comment string) {
 	if comment != "" {
@@ -65,3 +136,24 @@ func AddHosts(ip string, hosts []string, comment string) {
 
 	saveHosts()
 }
+
+// AddHostsWithTTL adds hostnames to an IP address with an optional comment
+// and an expiry ttl from now.
+func AddHostsWithTTL(ip string, hosts []string, comment string, ttl time.Duration) {
+	if err := etcHosts.AddHostsWithCommentTTL(ip, hosts, comment, ttl); err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		os.Exit(1)
+	}
+
+	saveHosts()
+}
+
+// AddHostsWithOptions adds hostnames to an IP address with the given options.
+func AddHostsWithOptions(ip string, hosts []string, opts txeh.AddOptions) {
+	if err := etcHosts.AddHostsWithOptions(ip, hosts, opts); err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		os.Exit(1)
+	}
+
+	saveHosts()
+}
@@ -0,0 +1,32 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(backupCmd)
+}
+
+var backupCmd = &cobra.Command{
+	Use:   "backup [list|restore]",
+	Short: "List or restore hosts file backups",
+	Long: `List or restore the timestamped backups txeh creates before writing
+the hosts file when run with --backup.
+
+Backups are stored next to the hosts file (e.g. /etc/hosts.txeh-20261016120000)
+or in the directory given by --backup-dir.`,
+	Run: func(cmd *cobra.Command, _ []string) {
+		err := cmd.Help()
+		if err != nil {
+			fmt.Printf("Error: can not display help, reason: %s\n", err.Error())
+			os.Exit(1)
+		}
+
+		fmt.Println("Please specify a sub-command such as \"list\" or \"restore\"")
+		os.Exit(1)
+	},
+}
@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	backupCmd.AddCommand(backupListCmd)
+}
+
+var backupListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List hosts file backups",
+	Long:  `List the backups of /etc/hosts, newest first`,
+	Args: func(_ *cobra.Command, _ []string) error {
+		return nil
+	},
+	Run: func(_ *cobra.Command, _ []string) {
+		ListBackups()
+	},
+}
+
+// ListBackups prints the available backups of the hosts file, newest first.
+func ListBackups() {
+	backups, err := etcHosts.ListBackups()
+	if err != nil {
+		fmt.Printf("Error: could not list backups. Reason: %s\n", err.Error())
+		os.Exit(1)
+	}
+
+	if len(backups) == 0 {
+		if !Quiet {
+			fmt.Printf("No backups found for %s\n", etcHosts.WriteFilePath)
+		}
+		return
+	}
+
+	for _, b := range backups {
+		fmt.Printf("%s  %s  %6d  %s\n", b.ID, b.Time.Local().Format(time.DateTime), b.Size, b.Path)
+	}
+}
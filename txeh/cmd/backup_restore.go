@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/spf13/cobra"
+
+	"github.com/txn2/txeh"
+)
+
+func init() {
+	backupCmd.AddCommand(backupRestoreCmd)
+}
+
+var backupRestoreCmd = &cobra.Command{
+	Use:   "restore [ID]",
+	Short: "Restore a hosts file backup",
+	Long: `Replace /etc/hosts with the backup identified by ID.
+
+Use "txeh backup list" to find backup IDs. Combine with --backup to keep a
+backup of the file being replaced, so the restore itself can be undone.`,
+	Args: func(_ *cobra.Command, args []string) error {
+		if len(args) != 1 {
+			return errors.New("the \"backup restore\" command requires exactly one backup ID")
+		}
+
+		return nil
+	},
+	Run: func(_ *cobra.Command, args []string) {
+		if !Quiet && !DryRun {
+			fmt.Printf("Restoring backup \"%s\" to %s\n", args[0], etcHosts.WriteFilePath)
+		}
+
+		RestoreBackup(args[0])
+	},
+}
+
+// RestoreBackup replaces the hosts file with the backup identified by id.
+// With DryRun, the backup content is printed instead.
+func RestoreBackup(id string) {
+	if DryRun {
+		backups, err := etcHosts.ListBackups()
+		if err != nil {
+			fmt.Printf("Error: could not list backups. Reason: %s\n", err.Error())
+			os.Exit(1)
+		}
+		idx := slices.IndexFunc(backups, func(b txeh.Backup) bool { return b.ID == id })
+		if idx < 0 {
+			fmt.Printf("Error: backup \"%s\" not found\n", id)
+			os.Exit(1)
+		}
+		data, err := os.ReadFile(filepath.Clean(backups[idx].Path))
+		if err != nil {
+			fmt.Printf("Error: could not read backup. Reason: %s\n", err.Error())
+			os.Exit(1)
+		}
+		fmt.Print(string(data))
+		_ = etcHosts.ReleaseLock()
+		return
+	}
+
+	if err := etcHosts.RestoreBackup(id); err != nil {
+		var flushErr *txeh.FlushError
+		if errors.As(err, &flushErr) {
+			fmt.Fprintf(os.Stderr, "Warning: backup restored but DNS cache flush failed: %s\n", flushErr)
+			return
+		}
+		fmt.Fprintf(os.Stderr, "Error: could not restore backup \"%s\". Reason: %s\n", id, err)
+		os.Exit(1)
+	}
+}
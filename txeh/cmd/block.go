@@ -0,0 +1,35 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(blockCmd)
+}
+
+var blockCmd = &cobra.Command{
+	Use:   "block [set|show|rm]",
+	Short: "Manage named blocks in /etc/hosts",
+	Long: `Manage named blocks of entries delimited by marker comments:
+
+  # BEGIN txeh:myapp
+  127.0.0.1        api.myapp.local
+  # END txeh:myapp
+
+A block belongs to one tool. Its content is replaced as a whole by
+"block set" and deleted with "block rm", without touching any other line.`,
+	Run: func(cmd *cobra.Command, _ []string) {
+		err := cmd.Help()
+		if err != nil {
+			fmt.Printf("Error: can not display help, reason: %s\n", err.Error())
+			os.Exit(1)
+		}
+
+		fmt.Println("Please specify a sub-command such as \"set\", \"show\" or \"rm\"")
+		os.Exit(1)
+	},
+}
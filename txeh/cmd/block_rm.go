@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	blockCmd.AddCommand(blockRmCmd)
+}
+
+var blockRmCmd = &cobra.Command{
+	Use:   "rm [NAME]",
+	Short: "Remove a block",
+	Long:  `Remove the block NAME, including its marker comments, from /etc/hosts`,
+	Args: func(_ *cobra.Command, args []string) error {
+		if len(args) != 1 {
+			return errors.New("the \"block rm\" command requires exactly one block name")
+		}
+
+		return nil
+	},
+	Run: func(_ *cobra.Command, args []string) {
+		if !Quiet {
+			fmt.Printf("Removing block \"%s\"\n", args[0])
+		}
+
+		RemoveBlock(args[0])
+	},
+}
+
+// RemoveBlock removes the named block and saves.
+func RemoveBlock(name string) {
+	if !etcHosts.RemoveBlock(name) {
+		fmt.Printf("Error: block \"%s\" not found\n", name)
+		os.Exit(1)
+	}
+
+	saveHosts()
+}
@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/txn2/txeh"
+)
+
+func init() {
+	blockCmd.AddCommand(blockSetCmd)
+}
+
+var blockSetCmd = &cobra.Command{
+	Use:   "set [NAME] \"[IP] [HOSTNAME] [HOSTNAME]...\"...",
+	Short: "Replace the content of a block",
+	Long: `Replace the content of the block NAME with the given entries, creating
+the block at the end of /etc/hosts if it does not exist. Each entry is one
+quoted argument holding an IP address followed by one or more hostnames.
+With no entries the block is emptied but kept.
+
+Examples:
+  txeh block set myapp "127.0.0.1 api.myapp.local web.myapp.local"
+  txeh block set kubefwd "127.1.27.1 svc-a" "127.1.27.2 svc-b"`,
+	Args: func(_ *cobra.Command, args []string) error {
+		if len(args) < 1 {
+			return errors.New("the \"block set\" command requires a block name")
+		}
+
+		_, err := parseBlockEntries(args[1:])
+		return err
+	},
+	Run: func(_ *cobra.Command, args []string) {
+		entries, _ := parseBlockEntries(args[1:])
+
+		if !Quiet {
+			fmt.Printf("Setting block \"%s\" (%d entries)\n", args[0], len(entries))
+		}
+
+		SetBlock(args[0], entries)
+	},
+}
+
+// parseBlockEntries parses "IP HOSTNAME..." arguments into block entries.
+func parseBlockEntries(args []string) ([]txeh.BlockEntry, error) {
+	entries := make([]txeh.BlockEntry, 0, len(args))
+	for _, arg := range args {
+		fields := strings.Fields(arg)
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("\"%s\" must be an IP address followed by at least one hostname", arg)
+		}
+		if !validateIPAddress(fields[0]) {
+			return nil, fmt.Errorf("\"%s\" is not a valid ipv4 or ipv6 address", fields[0])
+		}
+		if ok, hn := validateHostnames(fields[1:]); !ok {
+			return nil, fmt.Errorf("\"%s\" is not a valid hostname", hn)
+		}
+		entries = append(entries, txeh.BlockEntry{Address: fields[0], Hostnames: fields[1:]})
+	}
+
+	return entries, nil
+}
+
+// SetBlock replaces the content of the named block and saves.
+func SetBlock(name string, entries []txeh.BlockEntry) {
+	if err := etcHosts.SetBlock(name, entries); err != nil {
+		fmt.Printf("Error: could not set block \"%s\". Reason: %s\n", name, err.Error())
+		os.Exit(1)
+	}
+
+	saveHosts()
+}
@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	blockCmd.AddCommand(blockShowCmd)
+}
+
+var blockShowCmd = &cobra.Command{
+	Use:   "show [NAME]",
+	Short: "Show the entries of a block",
+	Long:  `Show the address entries of the block NAME in /etc/hosts`,
+	Args: func(_ *cobra.Command, args []string) error {
+		if len(args) != 1 {
+			return errors.New("the \"block show\" command requires exactly one block name")
+		}
+
+		return nil
+	},
+	Run: func(_ *cobra.Command, args []string) {
+		ShowBlock(args[0])
+	},
+}
+
+// ShowBlock prints the entries of the named block.
+func ShowBlock(name string) {
+	entries, ok := etcHosts.Block(name)
+	if !ok {
+		fmt.Printf("Error: block \"%s\" not found\n", name)
+		os.Exit(1)
+	}
+
+	for _, e := range entries {
+		if e.Comment != "" {
+			fmt.Printf("%-16s %s # %s\n", e.Address, strings.Join(e.Hostnames, " "), e.Comment)
+			continue
+		}
+		fmt.Printf("%-16s %s\n", e.Address, strings.Join(e.Hostnames, " "))
+	}
+}
@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/txn2/txeh"
+)
+
+var (
+	flushDetect  bool
+	flushTimeout time.Duration
+	flushRetries int
+)
+
+func init() {
+	rootCmd.AddCommand(flushCmd)
+	flushCmd.Flags().BoolVar(&flushDetect, "detect", false, "report the DNS caches on this system instead of flushing")
+	flushCmd.Flags().DurationVar(&flushTimeout, "timeout", txeh.DefaultFlushTimeout, "kill a flush command running longer than this (0 waits forever)")
+	flushCmd.Flags().IntVar(&flushRetries, "retries", 0, "retry a failed flush this many times, backing off between attempts")
+}
+
+var flushCmd = &cobra.Command{
+	Use:   "flush",
+	Short: "Flush the DNS cache",
+	Long: `Flush the operating system's DNS cache so hosts file changes take effect
+immediately (systemd-resolved on Linux, mDNSResponder on macOS, the DNS
+Client service on Windows).
+
+With --detect, report the caching layers found on this system instead:
+which resolvers run, which of them txeh can flush and the nameservers of
+/etc/resolv.conf.
+
+  txeh flush --timeout 5s --retries 2
+  txeh flush --detect`,
+	Args: func(_ *cobra.Command, args []string) error {
+		if len(args) != 0 {
+			return errors.New("the \"flush\" command takes no arguments")
+		}
+		if flushTimeout < 0 || flushRetries < 0 {
+			return errors.New("--timeout and --retries must not be negative")
+		}
+
+		return nil
+	},
+	// flush does not read the hosts file.
+	PersistentPreRun: func(_ *cobra.Command, _ []string) {},
+	Run: func(_ *cobra.Command, _ []string) {
+		if flushDetect {
+			PrintResolvers(txeh.DetectResolvers())
+			return
+		}
+		FlushCache(txeh.FlushOptions{Timeout: flushTimeout, Retries: flushRetries})
+	},
+}
+
+// FlushCache flushes the operating system's DNS cache.
+func FlushCache(opts txeh.FlushOptions) {
+	if err := txeh.FlushDNSCacheContext(context.Background(), opts); err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		var flushErr *txeh.FlushError
+		if errors.As(err, &flushErr) && len(flushErr.Attempts) > 1 && !Quiet {
+			for i, a := range flushErr.Attempts {
+				fmt.Printf("  attempt %d (%s, %s): %s\n", i+1, a.Command, a.Duration.Round(time.Millisecond), a.Err)
+			}
+		}
+		os.Exit(1)
+	}
+
+	if !Quiet {
+		fmt.Println("DNS cache flushed.")
+	}
+}
+
+// PrintResolvers prints a resolver detection report.
+func PrintResolvers(report *txeh.ResolverReport) {
+	fmt.Printf("Platform: %s\n", report.Platform)
+	if len(report.Nameservers) > 0 {
+		fmt.Printf("Nameservers: %s\n", strings.Join(report.Nameservers, ", "))
+	}
+	for _, l := range report.Layers {
+		fmt.Println(l)
+	}
+	if len(report.Layers) == 0 && !Quiet {
+		fmt.Println("No DNS caches found; hosts file changes take effect immediately")
+	}
+}
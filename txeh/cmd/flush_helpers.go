@@ -13,6 +13,7 @@ import (
 func saveHosts() {
 	if DryRun {
 		fmt.Print(etcHosts.RenderHostsFile())
+		_ = etcHosts.ReleaseLock()
 		return
 	}
 
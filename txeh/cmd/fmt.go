@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/txn2/txeh"
+)
+
+var fmtOptions txeh.NormalizeOptions
+
+func init() {
+	rootCmd.AddCommand(fmtCmd)
+	fmtCmd.Flags().BoolVar(&fmtOptions.Merge, "merge", true, "merge lines with the same address and comment")
+	fmtCmd.Flags().BoolVar(&fmtOptions.Dedupe, "dedupe", true, "remove duplicate hostnames")
+	fmtCmd.Flags().BoolVar(&fmtOptions.Reflow, "reflow", true, "split lines longer than --max-hosts-per-line")
+	fmtCmd.Flags().BoolVar(&fmtOptions.Sort, "sort", false, "sort address lines by IP, then hostname, between comments and blank lines")
+}
+
+var fmtCmd = &cobra.Command{
+	Use:   "fmt",
+	Short: "Normalize /etc/hosts",
+	Long: `Normalize the address lines of /etc/hosts: merge lines with the same
+address and comment, remove duplicate hostnames and split lines that hold more
+hostnames than --max-hosts-per-line allows. Comments and blank lines stay in
+place, and managed blocks are formatted on their own.
+
+Fix a file that breaks Windows resolution:
+
+  txeh fmt --max-hosts-per-line 9
+
+Disable a step with --merge=false, --dedupe=false or --reflow=false.`,
+	Args: func(_ *cobra.Command, args []string) error {
+		if len(args) != 0 {
+			return errors.New("the \"fmt\" command takes no arguments")
+		}
+
+		return nil
+	},
+	Run: func(_ *cobra.Command, _ []string) {
+		if !Quiet {
+			fmt.Println("Normalizing hosts file")
+		}
+
+		Normalize(fmtOptions)
+	},
+}
+
+// Normalize rewrites the address lines of the hosts file and saves it.
+func Normalize(opts txeh.NormalizeOptions) {
+	etcHosts.Normalize(opts)
+	saveHosts()
+}
@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/txn2/txeh"
+)
+
+// Lint output formats.
+const (
+	lintFormatText = "text"
+	lintFormatJSON = "json"
+)
+
+var lintFormat string
+
+func init() {
+	rootCmd.AddCommand(lintCmd)
+	lintCmd.Flags().StringVar(&lintFormat, "format", lintFormatText, "Output format: text or json")
+}
+
+var lintCmd = &cobra.Command{
+	Use:   "lint",
+	Short: "Check /etc/hosts for problems",
+	Long: `Check /etc/hosts for duplicate and shadowed entries, unparseable lines,
+invalid addresses, over-long hostnames, lines with more hostnames than
+Windows resolves and a missing localhost entry.
+
+lint exits with status 1 when any problem has severity "error", so it can
+gate hosts file changes in CI:
+
+  txeh lint --read ./hosts --format json`,
+	Args: func(_ *cobra.Command, args []string) error {
+		if len(args) != 0 {
+			return errors.New("the \"lint\" command takes no arguments")
+		}
+		if lintFormat != lintFormatText && lintFormat != lintFormatJSON {
+			return fmt.Errorf("invalid --format %q: use text or json", lintFormat)
+		}
+
+		return nil
+	},
+	Run: func(_ *cobra.Command, _ []string) {
+		if !Lint(lintFormat) {
+			os.Exit(1)
+		}
+	},
+}
+
+// Lint prints the diagnostics for the hosts file in the given format and
+// reports whether it is free of errors.
+func Lint(format string) bool {
+	diags := etcHosts.Lint()
+
+	if format == lintFormatJSON {
+		if diags == nil {
+			diags = []txeh.Diagnostic{}
+		}
+		out, err := json.MarshalIndent(diags, "", "  ")
+		if err != nil {
+			fmt.Printf("Error: %s\n", err.Error())
+			os.Exit(1)
+		}
+		fmt.Println(string(out))
+	} else {
+		for _, d := range diags {
+			fmt.Printf("%s: %s\n", etcHosts.ReadFilePath, d)
+		}
+		if len(diags) == 0 && !Quiet {
+			fmt.Println("No problems found")
+		}
+	}
+
+	return !txeh.HasLintErrors(diags)
+}
@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/txn2/txeh"
+)
+
+func init() {
+	listCmd.AddCommand(listByTagCmd)
+}
+
+var listByTagCmd = &cobra.Command{
+	Use:   "bytag [SELECTOR]",
+	Short: "List hosts matching a tag selector",
+	Long: `List all hostnames whose inline comment tags match the selector in /etc/hosts.
+
+A selector is a comma-separated list of key=value pairs; every pair must match.
+
+Examples:
+  txeh list bytag owner=kubefwd
+  txeh list bytag owner=kubefwd,ns=default`,
+	Args: func(_ *cobra.Command, args []string) error {
+		if len(args) != 1 {
+			return errors.New("the \"list bytag\" command requires exactly one selector")
+		}
+
+		_, err := txeh.ParseSelector(args[0])
+		return err
+	},
+	Run: func(_ *cobra.Command, args []string) {
+		ListByTag(args[0])
+	},
+}
+
+// ListByTag lists hostnames on lines matching the tag selector.
+func ListByTag(selector string) {
+	hosts, err := etcHosts.ListHostsBySelector(selector)
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		os.Exit(1)
+	}
+	for _, h := range hosts {
+		fmt.Println(h)
+	}
+}
@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(pruneCmd)
+}
+
+var pruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Remove expired entries from /etc/hosts",
+	Long: `Remove every entry whose expires tag (set by "txeh add --ttl") has passed.
+
+The hosts file is only written when something expired, so prune is cheap to
+run from cron or a systemd timer:
+
+  */5 * * * * root txeh prune --quiet`,
+	Args: func(_ *cobra.Command, args []string) error {
+		if len(args) != 0 {
+			return errors.New("the \"prune\" command takes no arguments")
+		}
+
+		return nil
+	},
+	Run: func(_ *cobra.Command, _ []string) {
+		Prune(time.Now())
+	},
+}
+
+// Prune removes entries that expired at or before now and saves when any were removed.
+func Prune(now time.Time) {
+	pruned := etcHosts.PruneExpired(now)
+	if len(pruned) == 0 {
+		if !Quiet && !DryRun {
+			fmt.Println("No expired entries")
+		}
+		_ = etcHosts.ReleaseLock()
+		return
+	}
+
+	if !Quiet && !DryRun {
+		fmt.Printf("Removing expired host(s) \"%s\"\n", strings.Join(pruned, " "))
+	}
+
+	saveHosts()
+}
@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/txn2/txeh"
+)
+
+func init() {
+	removeCmd.AddCommand(removeTagCmd)
+}
+
+var removeTagCmd = &cobra.Command{
+	Use:   "bytag [SELECTOR]",
+	Short: "Remove all hosts matching a tag selector",
+	Long: `Remove all host entries whose inline comment tags match the selector from /etc/hosts.
+
+A selector is a comma-separated list of key=value pairs; every pair must match.
+
+Examples:
+  txeh remove bytag owner=kubefwd
+  txeh remove bytag owner=kubefwd,ns=default`,
+	Args: func(_ *cobra.Command, args []string) error {
+		if len(args) != 1 {
+			return errors.New("the \"remove bytag\" command requires exactly one selector")
+		}
+
+		_, err := txeh.ParseSelector(args[0])
+		return err
+	},
+	Run: func(_ *cobra.Command, args []string) {
+		if !Quiet {
+			fmt.Printf("Removing all hosts matching \"%s\"\n", args[0])
+		}
+
+		RemoveByTag(args[0])
+	},
+}
+
+// RemoveByTag removes all host entries matching the tag selector.
+func RemoveByTag(selector string) {
+	if err := etcHosts.RemoveBySelector(selector); err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		os.Exit(1)
+	}
+	saveHosts()
+}
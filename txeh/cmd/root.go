@@ -5,7 +5,8 @@ import (
 	"fmt"
 	"net"
 	"os"
-	"regexp"
+	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 
@@ -51,9 +52,20 @@ var (
 	Flush bool
 	// MaxHostsPerLine limits hostnames per line (0=auto, -1=unlimited, >0=explicit).
 	MaxHostsPerLine int
-
-	etcHosts      *txeh.Hosts
-	hostnameRegex *regexp.Regexp
+	// LockTimeout is how long to wait for the cross-process hosts file lock (0 disables locking).
+	LockTimeout time.Duration
+	// Backup creates a timestamped backup of the hosts file before writing.
+	Backup bool
+	// BackupDir overrides the directory backups are stored in.
+	BackupDir string
+	// BackupRetention is the number of backups kept (0=default, -1=unlimited).
+	BackupRetention int
+	// PreserveFormatting writes untouched lines back exactly as they were read.
+	PreserveFormatting bool
+	// LineEnding forces the line terminator of the written file ("auto", "lf" or "crlf").
+	LineEnding string
+
+	etcHosts *txeh.Hosts
 )
 
 func init() {
@@ -63,10 +75,12 @@ func init() {
 	rootCmd.PersistentFlags().StringVarP(&HostsFileWritePath, "write", "w", "", "(override) Path to write /etc/hosts file.")
 	rootCmd.PersistentFlags().BoolVarP(&Flush, "flush", "f", false, "flush DNS cache after modifying hosts file")
 	rootCmd.PersistentFlags().IntVarP(&MaxHostsPerLine, "max-hosts-per-line", "m", 0, "Max hostnames per line (0=auto, -1=unlimited, >0=explicit). Auto uses 9 on Windows.")
-
-	// validate hostnames (allow underscore for service records)
-	// disallow leading dots, trailing dots, and consecutive dots
-	hostnameRegex = regexp.MustCompile(`^[A-Za-z0-9_-]+(\.[A-Za-z0-9_-]+)*$`)
+	rootCmd.PersistentFlags().DurationVarP(&LockTimeout, "lock-timeout", "l", 0, "Wait up to this long for the hosts file lock held by other txeh users (e.g. 10s). 0 disables locking.")
+	rootCmd.PersistentFlags().BoolVarP(&Backup, "backup", "b", false, "back up the hosts file before modifying it")
+	rootCmd.PersistentFlags().StringVar(&BackupDir, "backup-dir", "", "(override) Directory for hosts file backups. Defaults to the hosts file directory.")
+	rootCmd.PersistentFlags().IntVar(&BackupRetention, "backup-retention", 0, "Number of backups to keep (0=default of 10, -1=unlimited).")
+	rootCmd.PersistentFlags().BoolVar(&PreserveFormatting, "preserve-formatting", false, "keep the original formatting of lines that are not modified")
+	rootCmd.PersistentFlags().StringVar(&LineEnding, "line-ending", "auto", "Line ending of the written file: auto (keep the file's own), lf or crlf.")
 }
 
 func validateCIDRs(cidrs []string) (ok bool, failed string) {
@@ -95,7 +109,7 @@ func validateIPAddresses(ips []string) (ok bool, failed string) {
 }
 
 func validateIPAddress(ip string) bool {
-	return net.ParseIP(ip) != nil
+	return txeh.ValidateAddress(ip) == nil
 }
 
 func validateHostnames(hostnames []string) (ok bool, failed string) {
@@ -108,32 +122,68 @@ func validateHostnames(hostnames []string) (ok bool, failed string) {
 	return true, ""
 }
 
+// validateHostname applies the library's hostname rules (see
+// txeh.ValidateHostname). Unicode names are accepted and checked in the
+// punycode form they are converted to, so "café.local" is valid as
+// "xn--caf-dma.local"; ASCII labels may hold letters, digits, '-' and '_'
+// (for service records).
 func validateHostname(hostname string) bool {
-	return hostnameRegex.MatchString(hostname)
+	return txeh.ValidateHostname(hostname) == nil
 }
 
 func emptyFilePaths() bool {
 	return HostsFileReadPath == "" && HostsFileWritePath == ""
 }
 
+// customConfig reports whether any flag requires a non-default HostsConfig.
+func customConfig() bool {
+	return MaxHostsPerLine != 0 || Flush || LockTimeout != 0 ||
+		Backup || BackupDir != "" || BackupRetention != 0 || PreserveFormatting ||
+		(LineEnding != "" && LineEnding != "auto")
+}
+
+// parseLineEnding converts the --line-ending flag value.
+func parseLineEnding(s string) (txeh.LineEnding, error) {
+	switch strings.ToLower(s) {
+	case "", "auto":
+		return txeh.LineEndingAuto, nil
+	case "lf":
+		return txeh.LineEndingLF, nil
+	case "crlf":
+		return txeh.LineEndingCRLF, nil
+	default:
+		return txeh.LineEndingAuto, fmt.Errorf("invalid --line-ending \"%s\" (want auto, lf or crlf)", s)
+	}
+}
+
 func initEtcHosts() {
 	if os.Getenv("TXEH_AUTO_FLUSH") == "1" {
 		Flush = true
 	}
 
-	var (
-		hosts *txeh.Hosts
-		err   error
-	)
+	var hosts *txeh.Hosts
+
+	lineEnding, err := parseLineEnding(LineEnding)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
 
-	if emptyFilePaths() && MaxHostsPerLine == 0 && !Flush {
+	if emptyFilePaths() && !customConfig() {
 		hosts, err = txeh.NewHostsDefault()
 	} else {
 		hosts, err = txeh.NewHosts(&txeh.HostsConfig{
-			ReadFilePath:    HostsFileReadPath,
-			WriteFilePath:   HostsFileWritePath,
-			MaxHostsPerLine: MaxHostsPerLine,
-			AutoFlush:       Flush,
+			ReadFilePath:       HostsFileReadPath,
+			WriteFilePath:      HostsFileWritePath,
+			MaxHostsPerLine:    MaxHostsPerLine,
+			AutoFlush:          Flush,
+			Flusher:            txeh.SystemFlusher{FlushOptions: txeh.FlushOptions{Timeout: txeh.DefaultFlushTimeout}},
+			LockTimeout:        LockTimeout,
+			Backup:             Backup,
+			BackupDir:          BackupDir,
+			BackupRetention:    BackupRetention,
+			PreserveFormatting: PreserveFormatting,
+			LineEnding:         lineEnding,
 		})
 	}
 
@@ -1,7 +1,7 @@
 package cmd
 
 import (
-	"fmt"
+	"os"
 
 	"github.com/spf13/cobra"
 )
@@ -24,5 +24,5 @@ var showCmd = &cobra.Command{
 
 // ShowHosts prints the current hosts file content.
 func ShowHosts() {
-	fmt.Print(etcHosts.RenderHostsFile())
+	_, _ = etcHosts.WriteTo(os.Stdout)
 }
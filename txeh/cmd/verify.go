@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(verifyCmd)
+}
+
+var verifyCmd = &cobra.Command{
+	Use:   "verify [hostname] [hostname]...",
+	Short: "Check that hostnames resolve as /etc/hosts says",
+	Long: `Resolve hostnames through the system resolver and compare the answers
+with /etc/hosts. Without arguments every hostname in the file is checked.
+
+For each mismatch verify names the likely cause: a hosts file other than
+the one the system reads, an earlier line shadowing a later one, an
+nsswitch.conf that does not consult files first, or a DNS cache holding an
+old answer. Caches are only blamed when the lookup goes through the
+system's resolver library; Go's own resolver, used by builds without cgo
+on Linux, reads /etc/hosts itself. verify exits with status 1 when any hostname does not resolve
+as expected:
+
+  sudo txeh add 127.0.0.1 myapp.local && txeh verify myapp.local`,
+	Args: func(_ *cobra.Command, args []string) error {
+		if ok, hn := validateHostnames(args); !ok {
+			return fmt.Errorf("\"%s\" is not a valid hostname", hn)
+		}
+
+		return nil
+	},
+	Run: func(_ *cobra.Command, args []string) {
+		if !Verify(args) {
+			os.Exit(1)
+		}
+	},
+}
+
+// Verify resolves the hostnames, or every hostname in the hosts file when
+// none are given, prints the results and reports whether all of them
+// resolve as the hosts file says.
+func Verify(hostnames []string) bool {
+	if len(hostnames) == 0 {
+		seen := make(map[string]bool)
+		for e := range etcHosts.Entries() {
+			if !seen[e.Hostname] {
+				seen[e.Hostname] = true
+				hostnames = append(hostnames, e.Hostname)
+			}
+		}
+	}
+
+	ok := true
+	for _, hn := range hostnames {
+		result := etcHosts.Verify(hn)
+		if !result.OK() {
+			ok = false
+			fmt.Println(result)
+		} else if !Quiet {
+			fmt.Println(result)
+		}
+	}
+
+	return ok
+}
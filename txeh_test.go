@@ -432,8 +432,8 @@ func TestListHostsByCIDR_InvalidCIDR_Panics(t *testing.T) {
 }
 
 // BUG #6: Windows line endings not preserved.
-// File: txeh.go:437-438.
-func TestWindowsLineEndings_NotPreserved(t *testing.T) {
+// File: txeh.go:437-438. Fixed: the detected line ending is reproduced.
+func TestWindowsLineEndings_Preserved(t *testing.T) {
 	input := "127.0.0.1 localhost\r\n192.168.1.1 server\r\n"
 	hosts, err := NewHosts(&HostsConfig{RawText: &input})
 	if err != nil {
@@ -441,8 +441,8 @@ func TestWindowsLineEndings_NotPreserved(t *testing.T) {
 	}
 
 	rendered := hosts.RenderHostsFile()
-	if !strings.Contains(rendered, "\r\n") {
-		t.Log("Windows line endings (CRLF) not preserved in output")
+	if strings.Count(rendered, "\r\n") != 2 || strings.Count(rendered, "\n") != 2 {
+		t.Errorf("Windows line endings (CRLF) not preserved in output: %q", rendered)
 	}
 }
 
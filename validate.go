@@ -0,0 +1,174 @@
+package txeh
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"regexp"
+	"slices"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+// MaxHostnameLength is the longest hostname accepted, in bytes (RFC 1123).
+const MaxHostnameLength = 253
+
+// MaxLabelLength is the longest label (dot-separated part) of a hostname, in bytes.
+const MaxLabelLength = 63
+
+// Validation sentinels. The error-returning methods wrap them in a
+// *ValidationError carrying the offending value; check with errors.Is.
+var (
+	ErrInvalidAddress  = errors.New("invalid IP address")
+	ErrInvalidHostname = errors.New("invalid hostname")
+	ErrHostnameTooLong = errors.New("hostname too long")
+	ErrLabelTooLong    = errors.New("hostname label too long")
+)
+
+// ValidationError reports an address or hostname that was rejected.
+type ValidationError struct {
+	// Value is the rejected input.
+	Value string
+	// Err is ErrInvalidAddress, ErrInvalidHostname, ErrHostnameTooLong or
+	// ErrLabelTooLong.
+	Err error
+}
+
+// Error implements the error interface.
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%q: %v", e.Value, e.Err)
+}
+
+// Unwrap returns the underlying sentinel, making errors.Is work.
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// hostnameRegex matches hostnames made of letters, digits, '-' and '_'
+// (allowed for service records) separated by single dots, with no leading or
+// trailing dot.
+var hostnameRegex = regexp.MustCompile(`^[A-Za-z0-9_-]+(\.[A-Za-z0-9_-]+)*$`)
+
+// ValidateHostname returns a *ValidationError when hostname cannot be written
+// to a hosts file. Internationalized names are checked in their punycode form
+// (see NormalizeHostname), so "café.local" is valid when "xn--caf-dma.local"
+// is. Limits follow RFC 1123: labels of at most 63 bytes that do not start or
+// end with '-', and at most 253 bytes in total.
+func ValidateHostname(hostname string) error {
+	ascii, err := hostnameToASCII(hostname)
+	if err != nil {
+		return &ValidationError{Value: hostname, Err: ErrInvalidHostname}
+	}
+	if len(ascii) > MaxHostnameLength {
+		return &ValidationError{Value: hostname, Err: ErrHostnameTooLong}
+	}
+	if !hostnameRegex.MatchString(ascii) {
+		return &ValidationError{Value: hostname, Err: ErrInvalidHostname}
+	}
+
+	for label := range strings.SplitSeq(ascii, ".") {
+		if len(label) > MaxLabelLength {
+			return &ValidationError{Value: hostname, Err: ErrLabelTooLong}
+		}
+		if strings.HasPrefix(label, "-") || strings.HasSuffix(label, "-") {
+			return &ValidationError{Value: hostname, Err: ErrInvalidHostname}
+		}
+		if hasACEPrefix(label) {
+			if _, err := idna.Lookup.ToUnicode(label); err != nil {
+				return &ValidationError{Value: hostname, Err: ErrInvalidHostname}
+			}
+		}
+	}
+
+	return nil
+}
+
+// NormalizeHostname returns hostname in the form txeh stores: trimmed,
+// lowercased, and with internationalized labels converted to punycode
+// ("Café.local" becomes "xn--caf-dma.local"). Internationalized labels are
+// mapped and NFC-normalized per UTS #46 before encoding. Invalid hostnames
+// produce a *ValidationError.
+func NormalizeHostname(hostname string) (string, error) {
+	s := strings.ToLower(strings.TrimSpace(hostname))
+	if err := ValidateHostname(s); err != nil {
+		return "", &ValidationError{Value: hostname, Err: errors.Unwrap(err)}
+	}
+	return hostnameToASCII(s)
+}
+
+// canonicalHostname normalizes a hostname for storage and lookup without
+// validating it. Names that cannot be converted to punycode are only
+// trimmed and lowercased.
+func canonicalHostname(hostname string) string {
+	s := stripLineBreaks(strings.ToLower(strings.TrimSpace(hostname)))
+	if ascii, err := hostnameToASCII(s); err == nil {
+		return ascii
+	}
+	return s
+}
+
+// hostnameForms returns the canonical (punycode) and the plain lowercased
+// form of a hostname to look up. Stored names match either, so lookups work
+// with Unicode input whether the file holds the punycode or the raw name.
+func hostnameForms(hostname string) (canonical, lower string) {
+	return canonicalHostname(hostname), strings.ToLower(strings.TrimSpace(hostname))
+}
+
+// ValidateAddress returns a *ValidationError when address is not an IPv4 or
+// IPv6 address.
+func ValidateAddress(address string) error {
+	if _, err := netip.ParseAddr(address); err != nil {
+		return &ValidationError{Value: address, Err: ErrInvalidAddress}
+	}
+	return nil
+}
+
+// validateEntry checks an address and its hostnames as the Add methods
+// normalize them (trimmed).
+func validateEntry(address string, hosts []string) error {
+	if err := ValidateAddress(strings.TrimSpace(address)); err != nil {
+		return err
+	}
+	for _, hst := range hosts {
+		if err := ValidateHostname(strings.TrimSpace(hst)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AddHostE is AddHost with validation: it returns a *ValidationError and
+// changes nothing when the address or hostname is invalid.
+func (h *Hosts) AddHostE(address, host string) error {
+	return h.AddHostsWithCommentE(address, []string{host}, "")
+}
+
+// AddHostWithCommentE is AddHostWithComment with validation.
+// See AddHostE.
+func (h *Hosts) AddHostWithCommentE(address, host, comment string) error {
+	return h.AddHostsWithCommentE(address, []string{host}, comment)
+}
+
+// AddHostsE is AddHosts with validation. Every hostname is checked before
+// any is added, so an invalid one leaves the hosts unchanged.
+func (h *Hosts) AddHostsE(address string, hosts []string) error {
+	return h.AddHostsWithCommentE(address, hosts, "")
+}
+
+// AddHostsWithCommentE is AddHostsWithComment with validation.
+// See AddHostsE.
+func (h *Hosts) AddHostsWithCommentE(address string, hosts []string, comment string) error {
+	if err := validateEntry(address, hosts); err != nil {
+		return err
+	}
+
+	hosts = slices.Clone(hosts)
+	h.mutate(func(x *Hosts) {
+		for _, hst := range hosts {
+			x.addHostWithCommentLocked(address, hst, comment)
+		}
+	})
+
+	return nil
+}
@@ -0,0 +1,109 @@
+package txeh
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestValidateHostname(t *testing.T) {
+	tests := []struct {
+		hostname string
+		want     error
+	}{
+		{"localhost", nil},
+		{"my-host.example.com", nil},
+		{"_sip._tcp.example.com", nil},
+		{"UPPER.case", nil},
+		{"", ErrInvalidHostname},
+		{".leading", ErrInvalidHostname},
+		{"trailing.", ErrInvalidHostname},
+		{"double..dot", ErrInvalidHostname},
+		{"with space", ErrInvalidHostname},
+		{"hash#tag", ErrInvalidHostname},
+		{"bad!char", ErrInvalidHostname},
+		{strings.Repeat("a.", 126) + "ab", ErrHostnameTooLong},
+	}
+	for _, tt := range tests {
+		err := ValidateHostname(tt.hostname)
+		if !errors.Is(err, tt.want) || (tt.want == nil && err != nil) {
+			t.Errorf("ValidateHostname(%q) = %v, want %v", tt.hostname, err, tt.want)
+		}
+	}
+}
+
+func TestValidateAddress(t *testing.T) {
+	for _, ok := range []string{"127.0.0.1", "::1", "fe80::1"} {
+		if err := ValidateAddress(ok); err != nil {
+			t.Errorf("ValidateAddress(%q) = %v", ok, err)
+		}
+	}
+	for _, bad := range []string{"", "localhost", "999.0.0.1", "10.0.0.0/8"} {
+		if err := ValidateAddress(bad); !errors.Is(err, ErrInvalidAddress) {
+			t.Errorf("ValidateAddress(%q) = %v, want ErrInvalidAddress", bad, err)
+		}
+	}
+}
+
+// Given an invalid address or hostname
+// When an E variant is called
+// Then a *ValidationError with the offending value is returned and nothing is added.
+func TestAddHostE_ReturnsValidationError(t *testing.T) {
+	hosts := newRawHosts(t, testHostsLocalhost)
+	before := hosts.RenderHostsFile()
+
+	err := hosts.AddHostE("not-an-ip", "host")
+	var ve *ValidationError
+	if !errors.As(err, &ve) || ve.Value != "not-an-ip" || !errors.Is(err, ErrInvalidAddress) {
+		t.Errorf("AddHostE(bad address) = %v", err)
+	}
+
+	err = hosts.AddHostsE(testIPv4Alt, []string{"good", "bad host", "also-good"})
+	if !errors.As(err, &ve) || ve.Value != "bad host" || !errors.Is(err, ErrInvalidHostname) {
+		t.Errorf("AddHostsE(bad hostname) = %v", err)
+	}
+
+	err = hosts.AddHostWithCommentE(testIPv4Alt, strings.Repeat("x", MaxHostnameLength+1), "c")
+	if !errors.Is(err, ErrHostnameTooLong) {
+		t.Errorf("AddHostWithCommentE(long) = %v", err)
+	}
+
+	if got := hosts.RenderHostsFile(); got != before {
+		t.Errorf("state changed after rejected adds:\n%s", got)
+	}
+}
+
+// Given valid input
+// When the E variants are called
+// Then they behave like the plain methods.
+func TestAddHostE_Valid(t *testing.T) {
+	hosts := newRawHosts(t, testHostsLocalhost)
+
+	if err := hosts.AddHostE(" "+testIPv4Alt+" ", " One "); err != nil {
+		t.Fatal(err)
+	}
+	if err := hosts.AddHostsWithCommentE(testIPv4Alt, []string{"two", "three"}, "note"); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := hosts.ListHostsByIP(testIPv4Alt); len(got) != 3 || got[0] != "one" {
+		t.Errorf("unexpected hosts %v", got)
+	}
+	if got := hosts.ListHostsByComment("note"); len(got) != 2 {
+		t.Errorf("unexpected commented hosts %v", got)
+	}
+}
+
+// Given AddHostsWithTags and AddHostsWithCommentTTL
+// When called with an invalid hostname
+// Then they return a validation error too.
+func TestTaggedAndTTLAdds_Validate(t *testing.T) {
+	hosts := newRawHosts(t, testHostsLocalhost)
+
+	if err := hosts.AddHostWithTags(testIPv4Alt, "bad..host", map[string]string{"a": "b"}); !errors.Is(err, ErrInvalidHostname) {
+		t.Errorf("AddHostWithTags = %v", err)
+	}
+	if err := hosts.AddHostsWithCommentTTL("nope", []string{"h"}, "", 1); !errors.Is(err, ErrInvalidAddress) {
+		t.Errorf("AddHostsWithCommentTTL = %v", err)
+	}
+}
@@ -0,0 +1,359 @@
+package txeh
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+)
+
+// VerifyStatus is the outcome of Verify.
+type VerifyStatus string
+
+// Statuses reported by Verify.
+const (
+	VerifyOK         VerifyStatus = "ok"           // The resolver returns the addresses the hosts file maps the host to.
+	VerifyMismatch   VerifyStatus = "mismatch"     // The resolver returns other addresses, or only some of them.
+	VerifyUnresolved VerifyStatus = "unresolved"   // The hosts file maps the host but the lookup failed.
+	VerifyNotInHosts VerifyStatus = "not-in-hosts" // The hosts file does not map the host.
+)
+
+// Likely causes of a mismatch or failed lookup reported by Verify.
+const (
+	VerifyCauseNotSystemFile = "not-system-file" // The hosts were loaded from or saved to a file the resolver does not read.
+	VerifyCauseUnsaved       = "unsaved-changes" // The hosts have changes that were not saved yet.
+	VerifyCauseShadowed      = "shadowed"        // The resolver only returns the first of several lines for the host.
+	VerifyCauseNSSwitch      = "nsswitch-order"  // nsswitch.conf consults another source before the hosts file.
+	VerifyCauseStaleCache    = "stale-cache"     // A DNS cache still holds an answer from before the change.
+	VerifyCauseUnknown       = "unknown"         // None of the above applies.
+)
+
+// Resolver paths reported in VerifyResult.ResolverPath.
+const (
+	ResolverPathLibc   = "libc"   // The system resolver library, which follows nsswitch.conf and goes through DNS caches.
+	ResolverPathGo     = "go"     // Go's own resolver, which reads the hosts file and queries DNS servers itself.
+	ResolverPathCustom = "custom" // A Resolver other than *net.Resolver.
+)
+
+// Resolver looks up the addresses of a host. *net.Resolver implements it;
+// tests can substitute a fake.
+type Resolver interface {
+	LookupNetIP(ctx context.Context, network, host string) ([]netip.Addr, error)
+}
+
+// VerifyOptions configure VerifyWithOptions.
+type VerifyOptions struct {
+	// Resolver does the lookup. Nil means net.DefaultResolver, which
+	// resolves like other programs on the system do.
+	Resolver Resolver
+}
+
+// VerifyResult compares what the resolver returns for a host with what the
+// hosts file says.
+type VerifyResult struct {
+	Host   string       `json:"host"`
+	Status VerifyStatus `json:"status"`
+	// Expected are the addresses the hosts file maps Host to, in file order.
+	Expected []string `json:"expected,omitempty"`
+	// Resolved are the addresses the resolver returned.
+	Resolved []string `json:"resolved,omitempty"`
+	// Cause is one of the VerifyCause constants when Status is
+	// VerifyMismatch or VerifyUnresolved, and Detail explains it.
+	Cause  string `json:"cause,omitempty"`
+	Detail string `json:"detail,omitempty"`
+	// Err is the lookup error when Status is VerifyUnresolved.
+	Err error `json:"-"`
+	// ResolverPath is how the lookup was made, one of the ResolverPath
+	// constants. A DNS cache is only named as the cause for
+	// ResolverPathLibc lookups; the other paths never consult one.
+	ResolverPath string `json:"resolver_path"`
+}
+
+// OK reports whether the host resolves as the hosts file says, or is not in
+// the hosts file at all.
+func (r VerifyResult) OK() bool {
+	return r.Status == VerifyOK || r.Status == VerifyNotInHosts
+}
+
+// String formats the result as "app.local: mismatch: hosts file has
+// 10.0.0.5, resolver returned 192.168.1.10 (stale-cache: ...)".
+func (r VerifyResult) String() string {
+	resolved := "does not resolve"
+	if len(r.Resolved) > 0 {
+		resolved = "resolver returned " + strings.Join(r.Resolved, ", ")
+	}
+
+	var s string
+	switch r.Status {
+	case VerifyOK:
+		return fmt.Sprintf("%s: ok (%s)", r.Host, strings.Join(r.Resolved, ", "))
+	case VerifyNotInHosts:
+		return fmt.Sprintf("%s: not in hosts file (%s)", r.Host, resolved)
+	case VerifyUnresolved:
+		s = fmt.Sprintf("%s: unresolved: hosts file has %s, lookup failed: %v", r.Host, strings.Join(r.Expected, ", "), r.Err)
+	default:
+		s = fmt.Sprintf("%s: %s: hosts file has %s, %s", r.Host, r.Status, strings.Join(r.Expected, ", "), resolved)
+	}
+	if r.Cause != "" {
+		s += fmt.Sprintf(" (%s: %s)", r.Cause, r.Detail)
+	}
+	return s
+}
+
+// nsswitchConf is the name service switch configuration read by Verify.
+// A variable so tests can replace it.
+var nsswitchConf = "/etc/nsswitch.conf"
+
+// detectResolversFunc is DetectResolvers, replaceable in tests.
+var detectResolversFunc = DetectResolvers
+
+// resolverPathFunc is resolverPath, replaceable in tests.
+var resolverPathFunc = resolverPath
+
+// Verify resolves host through the system resolver and compares the answer
+// with the addresses the hosts file maps it to. See VerifyWithOptions.
+func (h *Hosts) Verify(host string) VerifyResult {
+	return h.VerifyWithOptions(context.Background(), host, VerifyOptions{})
+}
+
+// VerifyWithOptions resolves host with opts.Resolver and compares the
+// answer with the addresses this Hosts maps it to. When they differ, the
+// result names the likely cause: changes that were not saved or were saved
+// elsewhere than the system hosts file, an earlier line shadowing a later
+// one, an nsswitch.conf that does not consult files first, or a DNS cache
+// (see DetectResolvers) holding an old answer. DNS caches are only blamed
+// when the lookup goes through the system resolver library (see
+// VerifyResult.ResolverPath): Go's own resolver, the default on Linux
+// without cgo, reads the hosts file itself.
+func (h *Hosts) VerifyWithOptions(ctx context.Context, host string, opts VerifyOptions) VerifyResult {
+	canonical, lower := hostnameForms(host)
+	result := VerifyResult{Host: canonical}
+
+	h.mu.Lock()
+	var expected []netip.Addr
+	var lines []int
+	for _, i := range h.hostLines(canonical, lower) {
+		if a := h.hostFileLines[i].addr; a.IsValid() && !slices.Contains(expected, a) {
+			expected = append(expected, a)
+			lines = append(lines, i+1)
+		}
+	}
+	notSystem := h.notSystemFileLocked()
+	unsaved := len(h.pending) > 0 || h.coalesce.dirty
+	h.mu.Unlock()
+
+	resolver := opts.Resolver
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	result.ResolverPath = resolverPathFunc(resolver)
+	resolved, err := resolver.LookupNetIP(ctx, "ip", canonical)
+	var got []netip.Addr
+	for _, a := range resolved {
+		if a = a.Unmap(); !slices.Contains(got, a) {
+			got = append(got, a)
+			result.Resolved = append(result.Resolved, a.String())
+		}
+	}
+	for _, a := range expected {
+		result.Expected = append(result.Expected, a.String())
+	}
+
+	switch {
+	case len(expected) == 0:
+		result.Status = VerifyNotInHosts
+		return result
+	case err != nil:
+		result.Status, result.Err = VerifyUnresolved, err
+	case containsAddrs(expected, got) && containsAddrs(got, expected):
+		result.Status = VerifyOK
+		return result
+	default:
+		result.Status = VerifyMismatch
+	}
+
+	switch {
+	case notSystem != "":
+		result.Cause, result.Detail = VerifyCauseNotSystemFile, notSystem
+	case unsaved:
+		result.Cause, result.Detail = VerifyCauseUnsaved, "the hosts have changes that were not saved"
+	case len(got) > 0 && containsAddrs(expected, got):
+		result.Cause, result.Detail = VerifyCauseShadowed, shadowDetail(expected, lines, got)
+	default:
+		result.Cause, result.Detail = systemCause(result.ResolverPath)
+	}
+	return result
+}
+
+// notSystemFileLocked explains why the resolver does not see this Hosts'
+// file, or returns "" when it is saved to the system hosts file. Must be
+// called with the lock held.
+func (h *Hosts) notSystemFileLocked() string {
+	if src := h.nonFileSource(); src != "" {
+		return "the hosts were loaded from HostsConfig." + src + ", which the resolver does not read"
+	}
+	if system := systemHostsFile(); !samePath(h.WriteFilePath, system) {
+		return fmt.Sprintf("the hosts are saved to %s but the resolver reads %s", h.WriteFilePath, system)
+	}
+	return ""
+}
+
+// samePath reports whether a and b name the same file, following symlinks
+// where they resolve.
+func samePath(a, b string) bool {
+	resolve := func(p string) string {
+		if real, err := filepath.EvalSymlinks(p); err == nil {
+			return real
+		}
+		return filepath.Clean(p)
+	}
+	return resolve(a) == resolve(b)
+}
+
+// containsAddrs reports whether every address of sub is in set, ignoring
+// IPv6 zones, which resolvers drop.
+func containsAddrs(set, sub []netip.Addr) bool {
+	for _, a := range sub {
+		if !slices.ContainsFunc(set, func(b netip.Addr) bool { return a.WithZone("") == b.WithZone("") }) {
+			return false
+		}
+	}
+	return true
+}
+
+// shadowDetail names an expected address the resolver left out and the
+// earlier line of the same family it returned instead.
+func shadowDetail(expected []netip.Addr, lines []int, got []netip.Addr) string {
+	for j, missing := range expected {
+		if containsAddrs(got, []netip.Addr{missing}) {
+			continue
+		}
+		for k := range j {
+			if expected[k].Is4() == missing.Is4() && containsAddrs(got, expected[k:k+1]) {
+				return fmt.Sprintf("line %d (%s) is shadowed by line %d (%s)", lines[j], missing, lines[k], expected[k])
+			}
+		}
+	}
+	return "the resolver returns only some of the addresses in the hosts file"
+}
+
+// systemCause looks for a cause outside the hosts file: the nsswitch.conf
+// order, then running DNS caches when the lookup went through path
+// ResolverPathLibc.
+func systemCause(path string) (cause, detail string) {
+	if sources := nsswitchHostsSources(nsswitchConf); len(sources) > 0 && sources[0] != "files" {
+		i := slices.Index(sources, "files")
+		if i < 0 {
+			return VerifyCauseNSSwitch, fmt.Sprintf("%s does not list files for hosts", nsswitchConf)
+		}
+		return VerifyCauseNSSwitch, fmt.Sprintf("%s consults %s before files", nsswitchConf, strings.Join(sources[:i], " "))
+	}
+
+	switch path {
+	case ResolverPathGo:
+		return VerifyCauseUnknown, "the answer does not come from the hosts file; Go's resolver reads " + systemHostsFile() + " itself, so DNS caches are not involved"
+	case ResolverPathCustom:
+		return VerifyCauseUnknown, "the answer does not come from the hosts file"
+	}
+
+	var running []string
+	for _, l := range detectResolversFunc().Layers {
+		if l.Running {
+			running = append(running, l.Name)
+		}
+	}
+	if len(running) > 0 {
+		return VerifyCauseStaleCache, strings.Join(running, ", ") + " may still cache an old answer; run txeh flush"
+	}
+
+	return VerifyCauseUnknown, "the answer does not come from the hosts file"
+}
+
+// nsswitchHostsSources returns the sources of the hosts database in an
+// nsswitch.conf, without the [STATUS=action] items, or nil when the file or
+// the entry is missing.
+func nsswitchHostsSources(path string) []string {
+	f, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return nil
+	}
+	defer func() { _ = f.Close() }()
+
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line, _, _ := strings.Cut(sc.Text(), "#")
+		db, entry, ok := strings.Cut(line, ":")
+		if !ok || strings.TrimSpace(db) != "hosts" {
+			continue
+		}
+
+		var sources []string
+		inAction := false
+		for _, field := range strings.Fields(entry) {
+			switch {
+			case inAction || strings.HasPrefix(field, "["):
+				inAction = !strings.HasSuffix(field, "]")
+			default:
+				sources = append(sources, field)
+			}
+		}
+		return sources
+	}
+	return nil
+}
+
+// resolverPath tells whether lookups through r go through the system
+// resolver library or Go's own resolver, following the rules of the net
+// package: darwin and Windows always use the system, other platforms use it
+// only with cgo when GODEBUG=netdns=cgo is set or nsswitch.conf lists
+// sources Go does not implement. Resolvers other than *net.Resolver are
+// ResolverPathCustom.
+func resolverPath(r Resolver) string {
+	nr, ok := r.(*net.Resolver)
+	if !ok {
+		return ResolverPathCustom
+	}
+
+	mode := godebugNetDNS()
+	switch {
+	case nr.PreferGo || mode == "go":
+		return ResolverPathGo
+	case runtime.GOOS == "darwin" || runtime.GOOS == "ios" || runtime.GOOS == osWindows:
+		return ResolverPathLibc
+	case !cgoResolver:
+		return ResolverPathGo
+	case mode == "cgo":
+		return ResolverPathLibc
+	}
+
+	for _, src := range nsswitchHostsSources(nsswitchConf) {
+		if src != "files" && src != "dns" && src != "myhostname" {
+			return ResolverPathLibc
+		}
+	}
+	return ResolverPathGo
+}
+
+// godebugNetDNS returns the resolver forced by GODEBUG=netdns ("go" or
+// "cgo"), or "".
+func godebugNetDNS() string {
+	for setting := range strings.SplitSeq(os.Getenv("GODEBUG"), ",") {
+		key, value, _ := strings.Cut(setting, "=")
+		if key != "netdns" {
+			continue
+		}
+		// The value may carry a debug level, e.g. "cgo+1" or "2+go".
+		for part := range strings.SplitSeq(value, "+") {
+			if part == "go" || part == "cgo" {
+				return part
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,236 @@
+package txeh
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// fakeResolver answers from a map and fails like a DNS miss otherwise.
+type fakeResolver map[string][]string
+
+func (r fakeResolver) LookupNetIP(_ context.Context, _, host string) ([]netip.Addr, error) {
+	addrs, ok := r[host]
+	if !ok {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	var out []netip.Addr
+	for _, a := range addrs {
+		out = append(out, netip.MustParseAddr(a))
+	}
+	return out, nil
+}
+
+// newSystemHosts returns hosts read from a temp file holding content and
+// saved to the system hosts file, so Verify treats them as what the
+// resolver reads.
+func newSystemHosts(t *testing.T, content string) *Hosts {
+	t.Helper()
+	hosts, err := NewHosts(&HostsConfig{
+		ReadFilePath:  writeHostsFile(t, content),
+		WriteFilePath: systemHostsFile(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hosts
+}
+
+// stubSystem points the nsswitch.conf, resolver path and cache detection
+// Verify consults at test values for the duration of the test.
+func stubSystem(t *testing.T, nsswitch, path string, layers ...ResolverLayer) {
+	t.Helper()
+	origConf, origDetect, origPath := nsswitchConf, detectResolversFunc, resolverPathFunc
+	t.Cleanup(func() { nsswitchConf, detectResolversFunc, resolverPathFunc = origConf, origDetect, origPath })
+
+	resolverPathFunc = func(Resolver) string { return path }
+	nsswitchConf = filepath.Join(t.TempDir(), "nsswitch.conf")
+	if nsswitch != "" {
+		nsswitchConf = writeHostsFile(t, nsswitch)
+	}
+	detectResolversFunc = func() *ResolverReport { return &ResolverReport{Layers: layers} }
+}
+
+// Given a host the resolver answers from the hosts file
+// When it is verified
+// Then the result is ok, also when the resolver returns IPv4-mapped addresses.
+func TestVerify_OK(t *testing.T) {
+	t.Parallel()
+
+	hosts := newSystemHosts(t, "10.0.0.5 App.local\n::1 app.local\n")
+	for _, answer := range [][]string{{"10.0.0.5", "::1"}, {"::ffff:10.0.0.5", "::1", "::1"}} {
+		r := hosts.VerifyWithOptions(context.Background(), "APP.local", VerifyOptions{Resolver: fakeResolver{"app.local": answer}})
+		if r.Status != VerifyOK || !r.OK() {
+			t.Errorf("answer %v: %s", answer, r)
+		}
+		if !slices.Equal(r.Expected, []string{"10.0.0.5", "::1"}) || !slices.Equal(r.Resolved, []string{"10.0.0.5", "::1"}) {
+			t.Errorf("answer %v: Expected = %v, Resolved = %v", answer, r.Expected, r.Resolved)
+		}
+	}
+}
+
+// Given a host the hosts file does not map
+// When it is verified
+// Then it is reported as not in the hosts file, with what DNS returns.
+func TestVerify_NotInHosts(t *testing.T) {
+	t.Parallel()
+
+	hosts := newSystemHosts(t, testHostsLocalhost)
+	r := hosts.VerifyWithOptions(context.Background(), "example.com", VerifyOptions{Resolver: fakeResolver{"example.com": {"192.0.2.1"}}})
+	if r.Status != VerifyNotInHosts || !r.OK() || r.Cause != "" {
+		t.Errorf("result = %+v", r)
+	}
+	if want := "example.com: not in hosts file (resolver returned 192.0.2.1)"; r.String() != want {
+		t.Errorf("String() = %q, want %q", r.String(), want)
+	}
+}
+
+// Given hosts parsed from a string or saved to another file
+// When a host resolves differently
+// Then the cause is that the resolver does not read that file.
+func TestVerify_NotSystemFile(t *testing.T) {
+	t.Parallel()
+
+	resolver := fakeResolver{"app.local": {"192.0.2.1"}}
+	opts := VerifyOptions{Resolver: resolver}
+
+	raw := newRawHosts(t, "10.0.0.5 app.local\n")
+	r := raw.VerifyWithOptions(context.Background(), "app.local", opts)
+	if r.Status != VerifyMismatch || r.Cause != VerifyCauseNotSystemFile || !strings.Contains(r.Detail, "RawText") {
+		t.Errorf("RawText: %s", r)
+	}
+
+	path := writeHostsFile(t, "10.0.0.5 app.local\n")
+	file, err := NewHosts(&HostsConfig{ReadFilePath: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r = file.VerifyWithOptions(context.Background(), "app.local", opts)
+	if r.Cause != VerifyCauseNotSystemFile || !strings.Contains(r.Detail, path) {
+		t.Errorf("file: %s", r)
+	}
+}
+
+// Given a host added in memory but not saved
+// When it is verified
+// Then the lookup fails and the cause is the unsaved change.
+func TestVerify_Unsaved(t *testing.T) {
+	t.Parallel()
+
+	hosts := newSystemHosts(t, testHostsLocalhost)
+	hosts.AddHost("10.0.0.5", "new.local")
+
+	r := hosts.VerifyWithOptions(context.Background(), "new.local", VerifyOptions{Resolver: fakeResolver{}})
+	if r.Status != VerifyUnresolved || r.Cause != VerifyCauseUnsaved || r.Err == nil || r.OK() {
+		t.Errorf("result = %s", r)
+	}
+}
+
+// Given a hostname on two lines of the same family
+// When the resolver returns only the first
+// Then the later line is reported as shadowed.
+func TestVerify_Shadowed(t *testing.T) {
+	t.Parallel()
+
+	hosts := newSystemHosts(t, "192.168.1.10 nas.home\n10.8.0.5 nas.home\n")
+	r := hosts.VerifyWithOptions(context.Background(), "nas.home", VerifyOptions{Resolver: fakeResolver{"nas.home": {"192.168.1.10"}}})
+
+	if r.Status != VerifyMismatch || r.Cause != VerifyCauseShadowed {
+		t.Fatalf("result = %s", r)
+	}
+	if want := "line 2 (10.8.0.5) is shadowed by line 1 (192.168.1.10)"; r.Detail != want {
+		t.Errorf("Detail = %q, want %q", r.Detail, want)
+	}
+}
+
+// Given an answer that is not in the hosts file
+// When nsswitch.conf, running caches or neither explain it
+// Then the cause says so, blaming caches only for libc lookups.
+func TestVerify_SystemCauses(t *testing.T) {
+	resolver := fakeResolver{"app.local": {"192.0.2.1"}}
+	nscd := ResolverLayer{Name: ResolverNSCD, Running: true}
+
+	tests := []struct {
+		name     string
+		nsswitch string
+		path     string
+		layers   []ResolverLayer
+		cause    string
+		detail   string
+	}{
+		{"dns first", "passwd: files\nhosts: dns [NOTFOUND=return] files\n", ResolverPathGo, []ResolverLayer{nscd}, VerifyCauseNSSwitch, "consults dns before files"},
+		{"no files", "hosts: resolve dns\n", ResolverPathLibc, nil, VerifyCauseNSSwitch, "does not list files"},
+		{"running cache", "hosts: files mdns4_minimal [NOTFOUND=return] dns\n", ResolverPathLibc, []ResolverLayer{nscd, {Name: ResolverDnsmasq}}, VerifyCauseStaleCache, "nscd may still cache"},
+		{"no nsswitch", "", ResolverPathLibc, []ResolverLayer{nscd}, VerifyCauseStaleCache, "run txeh flush"},
+		{"cache bypassed by go", "hosts: files dns\n", ResolverPathGo, []ResolverLayer{nscd}, VerifyCauseUnknown, "DNS caches are not involved"},
+		{"cache with custom resolver", "", ResolverPathCustom, []ResolverLayer{nscd}, VerifyCauseUnknown, "does not come from the hosts file"},
+		{"nothing found", "hosts: files dns\n", ResolverPathLibc, nil, VerifyCauseUnknown, "does not come from the hosts file"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stubSystem(t, tt.nsswitch, tt.path, tt.layers...)
+			hosts := newSystemHosts(t, "10.0.0.5 app.local\n")
+
+			r := hosts.VerifyWithOptions(context.Background(), "app.local", VerifyOptions{Resolver: resolver})
+			if r.Status != VerifyMismatch || r.Cause != tt.cause || !strings.Contains(r.Detail, tt.detail) {
+				t.Errorf("result = %s, want cause %s with %q", r, tt.cause, tt.detail)
+			}
+			if r.ResolverPath != tt.path {
+				t.Errorf("ResolverPath = %q, want %q", r.ResolverPath, tt.path)
+			}
+		})
+	}
+}
+
+func TestNsswitchHostsSources(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		content string
+		want    []string
+	}{
+		{"hosts: files dns\n", []string{"files", "dns"}},
+		{"# hosts: dns\nhosts:\tfiles mdns4_minimal [NOTFOUND=return] dns # comment\n", []string{"files", "mdns4_minimal", "dns"}},
+		{"hosts: dns [ NOTFOUND=return ] files\n", []string{"dns", "files"}},
+		{"passwd: files\n", nil},
+	}
+	for _, tt := range tests {
+		if got := nsswitchHostsSources(writeHostsFile(t, tt.content)); !slices.Equal(got, tt.want) {
+			t.Errorf("nsswitchHostsSources(%q) = %q, want %q", tt.content, got, tt.want)
+		}
+	}
+	if got := nsswitchHostsSources(filepath.Join(t.TempDir(), "missing")); got != nil {
+		t.Errorf("missing file: %q", got)
+	}
+}
+
+// Given resolvers and GODEBUG settings that force a resolver path
+// When the path is determined
+// Then it follows the net package's choice.
+func TestResolverPath(t *testing.T) {
+	if got := resolverPath(fakeResolver{}); got != ResolverPathCustom {
+		t.Errorf("fake resolver: %q, want custom", got)
+	}
+	if got := resolverPath(&net.Resolver{PreferGo: true}); got != ResolverPathGo {
+		t.Errorf("PreferGo: %q, want go", got)
+	}
+
+	t.Setenv("GODEBUG", "madvdontneed=1,netdns=go+1")
+	if got := resolverPath(net.DefaultResolver); got != ResolverPathGo {
+		t.Errorf("GODEBUG=netdns=go+1: %q, want go", got)
+	}
+
+	t.Setenv("GODEBUG", "netdns=cgo")
+	want := ResolverPathGo
+	if cgoResolver || runtime.GOOS == "darwin" || runtime.GOOS == "ios" || runtime.GOOS == osWindows {
+		want = ResolverPathLibc
+	}
+	if got := resolverPath(net.DefaultResolver); got != want {
+		t.Errorf("GODEBUG=netdns=cgo: %q, want %q", got, want)
+	}
+}
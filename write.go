@@ -0,0 +1,138 @@
+package txeh
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// defaultHostsFileMode is the permission used when the target file does not
+// exist yet. Hosts files must be world-readable for name resolution to work.
+const defaultHostsFileMode fs.FileMode = 0o644
+
+// renameFunc is os.Rename, replaceable in tests.
+var renameFunc = os.Rename
+
+// writeFileAtomic replaces fileName with data without ever exposing a partially
+// written file. The content is written to a temporary file in the same
+// directory as the real target, synced to disk, given the mode and ownership of
+// the file it replaces, and then renamed over it. A crash or full disk at any
+// point before the rename leaves the original file untouched.
+//
+// A file that can't be renamed over because it is a mount point, like
+// /etc/hosts in a Docker or Kubernetes container, is truncated and rewritten
+// in place instead (see renameUnsupported); that write is not atomic.
+//
+// Symlinks are resolved first so the real file is replaced rather than the
+// link (on macOS /etc is a symlink to /private/etc).
+func writeFileAtomic(fileName string, data []byte) error {
+	target, err := resolveWriteTarget(filepath.Clean(fileName))
+	if err != nil {
+		return err
+	}
+
+	mode := defaultHostsFileMode
+	existing, err := os.Stat(target)
+	switch {
+	case err == nil:
+		mode = existing.Mode().Perm()
+	case !errors.Is(err, fs.ErrNotExist):
+		return fmt.Errorf("stat %s: %w", target, err)
+	}
+
+	dir := filepath.Dir(target)
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(target)+".txeh-*")
+	if err != nil {
+		return fmt.Errorf("create temp file in %s: %w", dir, err)
+	}
+	tmpName := tmp.Name()
+
+	// Remove the temp file on any failure path. After a successful rename
+	// the name no longer exists and Remove is a harmless no-op.
+	defer func() { _ = os.Remove(tmpName) }()
+
+	if err := writeAndSync(tmp, data); err != nil {
+		return fmt.Errorf("write temp file %s: %w", tmpName, err)
+	}
+
+	if err := os.Chmod(tmpName, mode); err != nil {
+		return fmt.Errorf("chmod temp file %s: %w", tmpName, err)
+	}
+
+	if existing != nil {
+		if err := copyOwnership(tmpName, existing); err != nil {
+			return fmt.Errorf("chown temp file %s: %w", tmpName, err)
+		}
+	}
+
+	if err := renameFunc(tmpName, target); err != nil {
+		if existing != nil && renameUnsupported(err) {
+			return overwriteFile(target, data)
+		}
+		return fmt.Errorf("rename %s to %s: %w", tmpName, target, err)
+	}
+
+	// The new content is in place; failing to sync the directory only risks
+	// the rename on a crash, so it does not fail the write.
+	_ = syncDir(dir)
+
+	return nil
+}
+
+// overwriteFile truncates the existing file path and writes data to it,
+// keeping its inode, mode and ownership.
+func overwriteFile(path string, data []byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_TRUNC, 0) // #nosec G304 -- the hosts file being written
+	if err != nil {
+		return fmt.Errorf("open %s: %w", path, err)
+	}
+	if err := writeAndSync(f, data); err != nil {
+		return fmt.Errorf("write %s: %w", path, err)
+	}
+	return nil
+}
+
+// writeAndSync writes data to f, flushes it to stable storage and closes it.
+// The file is closed on every path.
+func writeAndSync(f *os.File, data []byte) error {
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// resolveWriteTarget follows symlinks in fileName and returns the path of the
+// file that should actually be replaced. A target that does not exist yet is
+// returned with only its directory resolved.
+func resolveWriteTarget(fileName string) (string, error) {
+	resolved, err := filepath.EvalSymlinks(fileName)
+	if err == nil {
+		return resolved, nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return "", fmt.Errorf("resolve %s: %w", fileName, err)
+	}
+
+	// The file itself may not exist (or may be a dangling symlink). Resolve
+	// the directory so the temp file still lands on the right filesystem.
+	if link, lerr := os.Readlink(fileName); lerr == nil {
+		if !filepath.IsAbs(link) {
+			link = filepath.Join(filepath.Dir(fileName), link)
+		}
+		fileName = filepath.Clean(link)
+	}
+
+	dir, err := filepath.EvalSymlinks(filepath.Dir(fileName))
+	if err != nil {
+		return "", fmt.Errorf("resolve %s: %w", filepath.Dir(fileName), err)
+	}
+
+	return filepath.Join(dir, filepath.Base(fileName)), nil
+}
@@ -0,0 +1,22 @@
+//go:build !unix
+
+package txeh
+
+import "io/fs"
+
+// copyOwnership is a no-op on platforms without POSIX ownership. On Windows
+// the replaced file inherits the ACLs of its directory.
+func copyOwnership(_ string, _ fs.FileInfo) error {
+	return nil
+}
+
+// syncDir is a no-op on platforms where directories cannot be fsynced.
+func syncDir(_ string) error {
+	return nil
+}
+
+// renameUnsupported reports whether a rename failed because the target can't
+// be replaced. Mount points are only handled on Unix.
+func renameUnsupported(_ error) bool {
+	return false
+}
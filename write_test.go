@@ -0,0 +1,176 @@
+package txeh
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// Given a hosts file with mode 0600
+// When SaveAs writes new content
+// Then the content is replaced and the mode is still 0600.
+func TestSaveAs_Atomic_PreservesMode(t *testing.T) {
+	if runtime.GOOS == osWindows {
+		t.Skip("POSIX permissions are not applicable on windows")
+	}
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "hosts")
+	if err := os.WriteFile(path, []byte(testHostsLocalhost), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	hosts, err := NewHosts(&HostsConfig{ReadFilePath: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hosts.AddHost(testIPv4Alt, "atomic")
+
+	if err := hosts.Save(); err != nil {
+		t.Fatalf("Save() error: %v", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("mode = %o, want 600", info.Mode().Perm())
+	}
+
+	content, _ := os.ReadFile(path) // #nosec G304 -- test file
+	if !strings.Contains(string(content), "atomic") {
+		t.Errorf("saved file missing new host, got %q", content)
+	}
+}
+
+// Given a target path that does not exist yet
+// When SaveAs writes to it
+// Then the file is created with mode 0644.
+func TestSaveAs_Atomic_NewFileDefaultMode(t *testing.T) {
+	if runtime.GOOS == osWindows {
+		t.Skip("POSIX permissions are not applicable on windows")
+	}
+
+	input := testHostsLocalhost
+	hosts := &Hosts{HostsConfig: &HostsConfig{}}
+	hfl, _ := ParseHostsFromString(input)
+	hosts.hostFileLines = hfl
+
+	path := filepath.Join(t.TempDir(), "hosts.new")
+	if err := hosts.SaveAs(path); err != nil {
+		t.Fatalf("SaveAs() error: %v", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != defaultHostsFileMode {
+		t.Errorf("mode = %o, want %o", info.Mode().Perm(), defaultHostsFileMode)
+	}
+}
+
+// Given a hosts path that is a symlink to the real file (as with /etc on macOS)
+// When SaveAs writes through the symlink
+// Then the real file is updated and the symlink is left in place.
+func TestSaveAs_Atomic_FollowsSymlink(t *testing.T) {
+	dir := t.TempDir()
+	realDir := filepath.Join(dir, "private")
+	if err := os.Mkdir(realDir, 0o750); err != nil {
+		t.Fatal(err)
+	}
+	realPath := filepath.Join(realDir, "hosts")
+	if err := os.WriteFile(realPath, []byte(testHostsLocalhost), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	linkPath := filepath.Join(dir, "hosts")
+	if err := os.Symlink(realPath, linkPath); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+
+	hosts, err := NewHosts(&HostsConfig{ReadFilePath: linkPath})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hosts.AddHost(testIPv4Alt, "vialink")
+	if err := hosts.Save(); err != nil {
+		t.Fatalf("Save() error: %v", err)
+	}
+
+	linfo, err := os.Lstat(linkPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if linfo.Mode()&os.ModeSymlink == 0 {
+		t.Error("symlink was replaced by a regular file")
+	}
+
+	content, _ := os.ReadFile(realPath) // #nosec G304 -- test file
+	if !strings.Contains(string(content), "vialink") {
+		t.Errorf("real file not updated, got %q", content)
+	}
+}
+
+// Given a successful SaveAs
+// When the target directory is listed
+// Then no temporary files are left behind.
+func TestSaveAs_Atomic_NoTempFilesLeft(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "hosts")
+	if err := os.WriteFile(path, []byte(testHostsLocalhost), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	hosts, err := NewHosts(&HostsConfig{ReadFilePath: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range 3 {
+		if err := hosts.Save(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		names := make([]string, 0, len(entries))
+		for _, e := range entries {
+			names = append(names, e.Name())
+		}
+		t.Errorf("expected only the hosts file, found %v", names)
+	}
+}
+
+// Given a target whose path is a directory
+// When SaveAs fails at the rename step
+// Then an error is returned and no temp file is left behind.
+func TestSaveAs_Atomic_RenameFailureCleansUp(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "hosts")
+	if err := os.Mkdir(target, 0o750); err != nil {
+		t.Fatal(err)
+	}
+	// A non-empty directory can never be replaced by a rename.
+	if err := os.WriteFile(filepath.Join(target, "keep"), nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	input := testHostsLocalhost
+	hosts := &Hosts{HostsConfig: &HostsConfig{}}
+	hosts.hostFileLines, _ = ParseHostsFromString(input)
+
+	if err := hosts.SaveAs(target); err == nil {
+		t.Fatal("expected error when target is a directory")
+	}
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("temp file left behind: %d entries in %s", len(entries), dir)
+	}
+}
@@ -0,0 +1,52 @@
+//go:build unix
+
+package txeh
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"syscall"
+)
+
+// copyOwnership gives path the same owner and group as the file described by
+// existing. Chown is skipped when nothing would change, so unprivileged users
+// can still write files they own.
+func copyOwnership(path string, existing fs.FileInfo) error {
+	st, ok := existing.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil
+	}
+
+	cur, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if cst, ok := cur.Sys().(*syscall.Stat_t); ok && cst.Uid == st.Uid && cst.Gid == st.Gid {
+		return nil
+	}
+
+	return os.Chown(path, int(st.Uid), int(st.Gid))
+}
+
+// syncDir fsyncs a directory so a completed rename survives a crash.
+func syncDir(dir string) error {
+	d, err := os.Open(dir) // #nosec G304 -- directory of the hosts file being written
+	if err != nil {
+		return fmt.Errorf("open directory %s: %w", dir, err)
+	}
+	defer func() { _ = d.Close() }()
+
+	if err := d.Sync(); err != nil {
+		return fmt.Errorf("sync directory %s: %w", dir, err)
+	}
+
+	return nil
+}
+
+// renameUnsupported reports whether a rename failed because the target can't
+// be replaced: EBUSY for a bind-mounted file, EXDEV across filesystems.
+func renameUnsupported(err error) bool {
+	return errors.Is(err, syscall.EBUSY) || errors.Is(err, syscall.EXDEV)
+}
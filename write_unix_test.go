@@ -0,0 +1,114 @@
+//go:build unix
+
+package txeh
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+// Given a hosts file owned by another user (requires root)
+// When SaveAs replaces it
+// Then the new file keeps the original owner and group.
+func TestSaveAs_Atomic_PreservesOwnership(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("requires root on a POSIX system")
+	}
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "hosts")
+	if err := os.WriteFile(path, []byte(testHostsLocalhost), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	const uid, gid = 65534, 65534
+	if err := os.Chown(path, uid, gid); err != nil {
+		t.Skipf("chown not permitted: %v", err)
+	}
+
+	hosts, err := NewHosts(&HostsConfig{ReadFilePath: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := hosts.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		t.Skip("no Stat_t available")
+	}
+	if st.Uid != uid || st.Gid != gid {
+		t.Errorf("owner = %d:%d, want %d:%d", st.Uid, st.Gid, uid, gid)
+	}
+}
+
+// Given a hosts file that can't be renamed over, like a bind mount
+// When SaveAs writes new content
+// Then the file is rewritten in place, keeping its inode and mode.
+func TestSaveAs_Atomic_RenameBusyRewritesInPlace(t *testing.T) {
+	origRename := renameFunc
+	defer func() { renameFunc = origRename }()
+
+	for _, errno := range []syscall.Errno{syscall.EBUSY, syscall.EXDEV} {
+		renameFunc = func(oldpath, newpath string) error {
+			return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: errno}
+		}
+
+		dir := t.TempDir()
+		target := filepath.Join(dir, "hosts")
+		if err := os.WriteFile(target, []byte("# a much longer original file that must be truncated\n"), 0o640); err != nil {
+			t.Fatal(err)
+		}
+		before, err := os.Stat(target)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		hosts := &Hosts{HostsConfig: &HostsConfig{}}
+		hosts.hostFileLines, _ = ParseHostsFromString(testHostsLocalhost)
+		if err := hosts.SaveAs(target); err != nil {
+			t.Fatalf("%v: SaveAs() error = %v", errno, err)
+		}
+
+		after, err := os.Stat(target)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !os.SameFile(before, after) || after.Mode().Perm() != 0o640 {
+			t.Errorf("%v: file replaced or mode changed: %v", errno, after.Mode())
+		}
+		if got := readHostsFile(t, target); got != hosts.RenderHostsFile() {
+			t.Errorf("%v: content = %q", errno, got)
+		}
+		if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+			t.Errorf("%v: temp file left behind: %d entries", errno, len(entries))
+		}
+	}
+}
+
+// Given a rename that fails for another reason
+// When SaveAs writes
+// Then the error is returned and the file is untouched.
+func TestSaveAs_Atomic_RenameOtherErrorNoFallback(t *testing.T) {
+	origRename := renameFunc
+	defer func() { renameFunc = origRename }()
+	renameFunc = func(oldpath, newpath string) error {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: syscall.EACCES}
+	}
+
+	target := writeHostsFile(t, "# original\n")
+	hosts := &Hosts{HostsConfig: &HostsConfig{}}
+	hosts.hostFileLines, _ = ParseHostsFromString(testHostsLocalhost)
+	if err := hosts.SaveAs(target); err == nil {
+		t.Fatal("expected the rename error")
+	}
+	if got := readHostsFile(t, target); got != "# original\n" {
+		t.Errorf("file changed: %q", got)
+	}
+}